@@ -0,0 +1,49 @@
+package pulse
+
+import "cmp"
+
+// Branch represents the time that has been spent on a
+// branch of a repository for a given time period.
+type Branch struct {
+	Name       string `bson:"name"`
+	DurationMs int64  `bson:"duration_ms"`
+}
+
+// merge takes two branches, merges them, and returns the result.
+func (a Branch) merge(b Branch) Branch {
+	return Branch{
+		Name:       cmp.Or(a.Name, b.Name),
+		DurationMs: a.DurationMs + b.DurationMs,
+	}
+}
+
+// Branches represents a slice of branches.
+type Branches []Branch
+
+// branchesByName takes a slice of branches and returns a map
+// where the branch name is the key and the branch the value.
+func branchesByName(branches Branches) map[string]Branch {
+	nameBranchMap := make(map[string]Branch)
+	for _, branch := range branches {
+		nameBranchMap[branch.Name] = branch
+	}
+	return nameBranchMap
+}
+
+// merge takes two slices of branches, merges them, and returns the result.
+func (a Branches) merge(b Branches) Branches {
+	aNames, bNames := branchesByName(a), branchesByName(b)
+	allNames := make(map[string]bool)
+	for name := range aNames {
+		allNames[name] = true
+	}
+	for name := range bNames {
+		allNames[name] = true
+	}
+
+	mergedBranches := make(Branches, 0, len(allNames))
+	for name := range allNames {
+		mergedBranches = append(mergedBranches, aNames[name].merge(bNames[name]))
+	}
+	return mergedBranches
+}
@@ -6,47 +6,216 @@ import (
 	"time"
 )
 
+// editWindow is the amount of time after a text change that we consider
+// to be spent editing the buffer. Any other active time is spent reading.
+const editWindow = 30 * time.Second
+
 // Buffer rerpresents a buffer that has been edited during a coding session.
+// Duration is the time that we consider active, and it's the only duration
+// that counts towards the aggregated sessions. WallDuration is the time
+// between the buffer being opened and closed, including any idle time.
+// The active time is further divided into time spent editing and reading.
 type Buffer struct {
-	OpenedAt   time.Time     `json:"-"`
-	ClosedAt   time.Time     `json:"-"`
-	Duration   time.Duration `json:"duration"`
-	Filename   string        `json:"filename"`
-	Filepath   string        `json:"filepath"`
-	Filetype   string        `json:"filetype"`
-	Repository string        `json:"repository"`
+	OpenedAt     time.Time     `json:"opened_at"`
+	ClosedAt     time.Time     `json:"closed_at"`
+	Duration     time.Duration `json:"duration"`
+	WallDuration time.Duration `json:"wall_duration"`
+	EditDuration time.Duration `json:"edit_duration"`
+	ReadDuration time.Duration `json:"read_duration"`
+	Writes       int           `json:"writes"`
+	LinesAdded   int           `json:"lines_added"`
+	LinesRemoved int           `json:"lines_removed"`
+	Filename     string        `json:"filename"`
+	Filepath     string        `json:"filepath"`
+	Filetype     string        `json:"filetype"`
+	Repository   string        `json:"repository"`
+	Branch       string        `json:"branch"`
+	Tickets      []string      `json:"tickets,omitempty"`
+	Workspace    bool          `json:"workspace,omitempty"`
+	Category     string        `json:"category,omitempty"`
+	Project      string        `json:"project,omitempty"`
+	// Redaction overrides the configured redaction rules for the repository.
+	Redaction *RedactionRule `json:"redaction,omitempty"`
+
+	// The spans of time that have been spent editing the buffer, and
+	// the span of time that is currently being spent editing it.
+	edits     []span
+	editStart time.Time
+	editEnd   time.Time
+}
+
+// span is a span of time between two points.
+type span struct {
+	start, end time.Time
+}
+
+// overlap returns the part of the span that is between from and to.
+func (s span) overlap(from, to time.Time) time.Duration {
+	start, end := s.start, s.end
+	if from.After(start) {
+		start = from
+	}
+	if to.Before(end) {
+		end = to
+	}
+	return max(end.Sub(start), 0)
 }
 
-// NewBuffer creates a new buffer.
-func NewBuffer(filename, repo, filetype, filepath string, openedAt time.Time) Buffer {
+// NewBuffer creates a new buffer for a file within a git repository.
+func NewBuffer(file GitFile, openedAt time.Time) Buffer {
+	redaction, _ := file.Config.RedactionRule(file.Repository)
 	return Buffer{
 		OpenedAt:   openedAt,
-		Filename:   filename,
-		Filepath:   filepath,
-		Filetype:   filetype,
-		Repository: repo,
+		Filename:   file.Name,
+		Filepath:   file.Path,
+		Filetype:   file.Filetype,
+		Repository: file.Repository,
+		Branch:     file.Branch,
+		Workspace:  file.Workspace,
+		Category:   file.Config.Category,
+		Project:    file.Project,
+		Redaction:  redaction,
+	}
+}
+
+// Edit records that the text of the buffer was changed.
+func (b *Buffer) Edit(at time.Time) {
+	if !b.editEnd.IsZero() && !at.After(b.editEnd) {
+		b.editEnd = at.Add(editWindow)
+		return
+	}
+
+	b.endEdit(b.editEnd)
+	b.editStart, b.editEnd = at, at.Add(editWindow)
+}
+
+// Write records that the buffer was written to disk.
+func (b *Buffer) Write() {
+	b.Writes++
+}
+
+// endEdit adds the current span of editing, up until the given time, to the edits.
+func (b *Buffer) endEdit(until time.Time) {
+	if b.editStart.IsZero() {
+		return
+	}
+
+	end := b.editEnd
+	if until.Before(end) {
+		end = until
+	}
+	if end.After(b.editStart) {
+		b.edits = append(b.edits, span{start: b.editStart, end: end})
 	}
+	b.editStart, b.editEnd = time.Time{}, time.Time{}
 }
 
 // Close should be called when the coding session ends, or another buffer is opened.
 func (b *Buffer) Close(closedAt time.Time) {
+	b.Expire(closedAt, closedAt)
+}
+
+// Expire closes a buffer that stopped receiving activity at activeUntil. The
+// time between activeUntil and closedAt is recorded as wall time only.
+func (b *Buffer) Expire(activeUntil, closedAt time.Time) {
+	if activeUntil.After(closedAt) {
+		activeUntil = closedAt
+	}
+	if activeUntil.Before(b.OpenedAt) {
+		activeUntil = b.OpenedAt
+	}
+
 	b.ClosedAt = closedAt
-	b.Duration = b.ClosedAt.Sub(b.OpenedAt)
+	b.Duration = activeUntil.Sub(b.OpenedAt)
+	b.WallDuration = b.ClosedAt.Sub(b.OpenedAt)
+
+	b.endEdit(activeUntil)
+	b.EditDuration = 0
+	for _, edit := range b.edits {
+		b.EditDuration += edit.overlap(b.OpenedAt, activeUntil)
+	}
+	b.EditDuration = min(b.EditDuration, b.Duration)
+	b.ReadDuration = b.Duration - b.EditDuration
 }
 
-// Key returns a unique identifier for the buffer.
-func (b *Buffer) Key() string {
-	return fmt.Sprintf("%s_%s_%s", b.OpenedAt.Format("2006-01-02"), b.Repository, b.Filepath)
+// Split closes the buffer like Expire, and splits it at the start of each day
+// of the calendar. The pieces are returned in chronological order,
+// and each of them is opened and closed on a single day. Pieces without any
+// active time are left out, unless there are no other pieces. The writes and
+// changed lines can't be attributed to a point in time, which is why they're
+// kept on the last piece that has active time.
+func (b *Buffer) Split(cal Calendar, activeUntil, closedAt time.Time) Buffers {
+	pieces := make(Buffers, 0, 1)
+	for {
+		_, lastMs := cal.DayRange(b.OpenedAt.UnixMilli())
+		nextDay := time.UnixMilli(lastMs + 1)
+		if !closedAt.After(nextDay) {
+			break
+		}
+
+		piece := *b
+		piece.Writes, piece.LinesAdded, piece.LinesRemoved = 0, 0, 0
+		piece.Expire(activeUntil, nextDay)
+		if piece.Duration > 0 {
+			pieces = append(pieces, piece)
+		}
+		b.OpenedAt = nextDay
+	}
+
+	b.Expire(activeUntil, closedAt)
+	if b.Duration > 0 || len(pieces) == 0 {
+		return append(pieces, *b)
+	}
+
+	// The buffer expired after midnight, but was last active the day before.
+	last := &pieces[len(pieces)-1]
+	last.Writes, last.LinesAdded, last.LinesRemoved = b.Writes, b.LinesAdded, b.LinesRemoved
+	return pieces
+}
+
+// Key returns a unique identifier for the buffer, which
+// starts with the date of the calendar that it was opened on.
+func (b *Buffer) Key(cal Calendar) string {
+	return fmt.Sprintf("%s_%s_%s_%s", cal.DateString(b.OpenedAt), b.Repository, b.Branch, b.Filepath)
 }
 
 // Merge takes two buffers, merges them, and returns the result.
 func (b *Buffer) Merge(other Buffer) Buffer {
+	tickets := b.Tickets
+	if len(tickets) == 0 {
+		tickets = other.Tickets
+	}
+
+	// The merged buffer spans from the first time it was opened, to the last
+	// time it was closed. It's needed to attribute the time to the right day.
+	openedAt, closedAt := b.OpenedAt, b.ClosedAt
+	if openedAt.IsZero() || (!other.OpenedAt.IsZero() && other.OpenedAt.Before(openedAt)) {
+		openedAt = other.OpenedAt
+	}
+	if other.ClosedAt.After(closedAt) {
+		closedAt = other.ClosedAt
+	}
+
 	return Buffer{
-		Filename:   cmp.Or(b.Filename, other.Filename),
-		Filepath:   cmp.Or(b.Filepath, other.Filepath),
-		Filetype:   cmp.Or(b.Filetype, other.Filetype),
-		Repository: cmp.Or(b.Repository, other.Repository),
-		Duration:   b.Duration + other.Duration,
+		OpenedAt:     openedAt,
+		ClosedAt:     closedAt,
+		Filename:     cmp.Or(b.Filename, other.Filename),
+		Filepath:     cmp.Or(b.Filepath, other.Filepath),
+		Filetype:     cmp.Or(b.Filetype, other.Filetype),
+		Repository:   cmp.Or(b.Repository, other.Repository),
+		Branch:       cmp.Or(b.Branch, other.Branch),
+		Tickets:      tickets,
+		Workspace:    b.Workspace || other.Workspace,
+		Category:     cmp.Or(b.Category, other.Category),
+		Project:      cmp.Or(b.Project, other.Project),
+		Redaction:    cmp.Or(b.Redaction, other.Redaction),
+		Duration:     b.Duration + other.Duration,
+		WallDuration: b.WallDuration + other.WallDuration,
+		EditDuration: b.EditDuration + other.EditDuration,
+		ReadDuration: b.ReadDuration + other.ReadDuration,
+		Writes:       b.Writes + other.Writes,
+		LinesAdded:   b.LinesAdded + other.LinesAdded,
+		LinesRemoved: b.LinesRemoved + other.LinesRemoved,
 	}
 }
 
@@ -0,0 +1,95 @@
+package pulse_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+func TestBufferIsSplitAtMidnight(t *testing.T) {
+	t.Parallel()
+
+	openedAt := time.Date(2024, time.January, 1, 23, 40, 0, 0, time.Local)
+	closedAt := time.Date(2024, time.January, 2, 0, 20, 0, 0, time.Local)
+
+	buf := pulse.Buffer{OpenedAt: openedAt, Filepath: "pulse/main.go", Repository: "pulse"}
+	buf.Edit(time.Date(2024, time.January, 1, 23, 59, 50, 0, time.Local))
+	buf.Write()
+
+	pieces := buf.Split(pulse.Calendar{}, closedAt, closedAt)
+	if len(pieces) != 2 {
+		t.Fatalf("expected one piece per day, got %d", len(pieces))
+	}
+
+	testCases := []struct {
+		key          string
+		duration     time.Duration
+		editDuration time.Duration
+		writes       int
+	}{
+		{"2024-01-01_pulse__pulse/main.go", 20 * time.Minute, 10 * time.Second, 0},
+		{"2024-01-02_pulse__pulse/main.go", 20 * time.Minute, 20 * time.Second, 1},
+	}
+	for i, tc := range testCases {
+		piece := pieces[i]
+		if piece.Key(pulse.Calendar{}) != tc.key {
+			t.Errorf("expected key %s, got %s", tc.key, piece.Key(pulse.Calendar{}))
+		}
+		if piece.Duration != tc.duration {
+			t.Errorf("expected %s to have a duration of %s, got %s", tc.key, tc.duration, piece.Duration)
+		}
+		if piece.EditDuration != tc.editDuration {
+			t.Errorf("expected %s to have an edit duration of %s, got %s", tc.key, tc.editDuration, piece.EditDuration)
+		}
+		if piece.Writes != tc.writes {
+			t.Errorf("expected %s to have %d writes, got %d", tc.key, tc.writes, piece.Writes)
+		}
+	}
+
+	if total := pieces[0].Duration + pieces[1].Duration; total != closedAt.Sub(openedAt) {
+		t.Errorf("expected the pieces to add up to %s, got %s", closedAt.Sub(openedAt), total)
+	}
+}
+
+func TestBufferThatExpiresAfterMidnightIsNotSplit(t *testing.T) {
+	t.Parallel()
+
+	openedAt := time.Date(2024, time.January, 1, 23, 50, 0, 0, time.Local)
+	activeUntil := time.Date(2024, time.January, 1, 23, 57, 0, 0, time.Local)
+	expiredAt := time.Date(2024, time.January, 2, 0, 6, 0, 0, time.Local)
+
+	buf := pulse.Buffer{OpenedAt: openedAt, Filepath: "pulse/main.go", Repository: "pulse"}
+	buf.Write()
+	buf.LinesAdded, buf.LinesRemoved = 3, 1
+
+	pieces := buf.Split(pulse.Calendar{}, activeUntil, expiredAt)
+	if len(pieces) != 1 {
+		t.Fatalf("expected the empty piece after midnight to be dropped, got %d pieces", len(pieces))
+	}
+	piece := pieces[0]
+	if key := piece.Key(pulse.Calendar{}); key != "2024-01-01_pulse__pulse/main.go" {
+		t.Errorf("expected key 2024-01-01_pulse__pulse/main.go, got %s", key)
+	}
+	if piece.Duration != 7*time.Minute {
+		t.Errorf("expected a duration of 7m, got %s", piece.Duration)
+	}
+	if piece.Writes != 1 || piece.LinesAdded != 3 || piece.LinesRemoved != 1 {
+		t.Errorf("expected the writes and lines to be kept, got %d writes and +%d -%d",
+			piece.Writes, piece.LinesAdded, piece.LinesRemoved)
+	}
+}
+
+func TestBufferWithinADayIsNotSplit(t *testing.T) {
+	t.Parallel()
+
+	openedAt := time.Date(2024, time.January, 1, 12, 0, 0, 0, time.Local)
+	buf := pulse.Buffer{OpenedAt: openedAt, Filepath: "pulse/main.go", Repository: "pulse"}
+	pieces := buf.Split(pulse.Calendar{}, openedAt.Add(10*time.Minute), openedAt.Add(time.Hour))
+	if len(pieces) != 1 {
+		t.Fatalf("expected a single piece, got %d", len(pieces))
+	}
+	if pieces[0].Duration != 10*time.Minute || pieces[0].WallDuration != time.Hour {
+		t.Errorf("expected 10m of active time over 1h, got %s over %s", pieces[0].Duration, pieces[0].WallDuration)
+	}
+}
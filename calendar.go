@@ -0,0 +1,56 @@
+package pulse
+
+import "time"
+
+// dateLayout is the layout of the date strings of the daily sessions.
+const dateLayout = "2006-01-02"
+
+// Calendar buckets the time into days, weeks, months, and years. The zero
+// value uses the local timezone of the machine, with days that start at midnight.
+type Calendar struct {
+	// Location is the timezone of the calendar. The local timezone is used if it's nil.
+	Location *time.Location
+	// DayStart is the time of day at which a new day starts. With a day start
+	// of 4h, the work that is done at 01:00 counts towards the previous day.
+	// It's measured on the clock of the timezone, which means that it's the
+	// same time of day across daylight saving time transitions.
+	DayStart time.Duration
+}
+
+// location returns the timezone that the time is bucketed in.
+func (c Calendar) location() *time.Location {
+	if c.Location != nil {
+		return c.Location
+	}
+	return time.Local
+}
+
+// dateOf returns midnight of the date that the time counts towards. It's
+// the previous date if the time is before the start of the day.
+func (c Calendar) dateOf(t time.Time) time.Time {
+	t = t.In(c.location())
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	if t.Before(c.startOfDay(midnight)) {
+		return midnight.AddDate(0, 0, -1)
+	}
+	return midnight
+}
+
+// startOfDay returns the time at which the day of the date starts.
+func (c Calendar) startOfDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, int(c.DayStart), date.Location())
+}
+
+// DateString returns the date that the time counts towards, e.g. 2024-01-31.
+func (c Calendar) DateString(t time.Time) string {
+	return c.dateOf(t).Format(dateLayout)
+}
+
+// ParseDate returns the time at which the day of the date string starts.
+func (c Calendar) ParseDate(dateString string) (time.Time, error) {
+	t, err := time.ParseInLocation(dateLayout, dateString, c.location())
+	if err != nil {
+		return time.Time{}, err
+	}
+	return c.startOfDay(t), nil
+}
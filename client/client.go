@@ -2,43 +2,122 @@
 package client
 
 import (
+	"bufio"
+	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"net/rpc"
 	"runtime"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/creativecreature/pulse"
 )
 
+// ErrUnauthorized is returned when the server rejects our token.
+var ErrUnauthorized = errors.New("the server rejected the token")
+
 // Client for making remote procedure calls to the server.
 type Client struct {
 	serverName string
 	rpcClient  *rpc.Client
+
+	mu           sync.Mutex
+	today        string
+	todayFetched time.Time
+
+	sentMu   sync.Mutex
+	lastSent map[pulse.EventType]sentEvent
 }
 
+// sentEvent is the most recent heartbeat that we sent for an event type.
+type sentEvent struct {
+	path string
+	at   time.Time
+}
+
+const (
+	// todayTTL is the amount of time that we'll reuse the time tracked today
+	// before asking the server again. The statusline is redrawn frequently.
+	todayTTL = 30 * time.Second
+	// heartbeatThrottle is the least amount of time between two heartbeats of
+	// the same type, for the same file. Text changes and cursor moves fire on
+	// every keystroke, and the server only needs to know that we're active.
+	heartbeatThrottle = 5 * time.Second
+)
+
 // createEvents creates a new event from the slice of arguments
 // that we receive from the neovim client.
-func createEvent(args []string) pulse.Event {
+func createEvent(args []string, eventType pulse.EventType) pulse.Event {
 	return pulse.Event{
 		EditorID: args[0],
 		Path:     args[1],
 		Editor:   "nvim",
 		OS:       runtime.GOOS,
+		Type:     eventType,
 	}
 }
 
-// New is used to create a new client.
-func New(serverName, port, hostname string) (*Client, error) {
-	rpcClient, err := rpc.DialHTTP("tcp", fmt.Sprintf("%s:%s", hostname, port))
+// dialHTTP works like rpc.DialHTTP, but presents the token to the server
+// when the connection is established. Every call that is made on the
+// connection is authenticated by that token.
+func dialHTTP(network, address, token string) (*rpc.Client, error) {
+	conn, err := net.Dial(network, address)
 	if err != nil {
 		return nil, err
 	}
 
-	return &Client{serverName: serverName, rpcClient: rpcClient}, nil
+	request := "CONNECT " + rpc.DefaultRPCPath + " HTTP/1.0\n"
+	if token != "" {
+		request += pulse.TokenHeader + ": " + token + "\n"
+	}
+	_, err = io.WriteString(conn, request+"\n")
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), &http.Request{Method: http.MethodConnect})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return rpc.NewClient(conn), nil
+	case http.StatusUnauthorized:
+		conn.Close()
+		return nil, ErrUnauthorized
+	default:
+		conn.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("unexpected response from the server: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+}
+
+// New is used to create a new client. The network is either tcp or unix.
+// The token is optional, and only required if the server has one.
+func New(serverName, network, address, token string) (*Client, error) {
+	rpcClient, err := dialHTTP(network, address, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		serverName: serverName,
+		rpcClient:  rpcClient,
+		lastSent:   make(map[pulse.EventType]sentEvent),
+	}, nil
 }
 
 // FocusGained should be called when a buffer gains focus.
 func (c *Client) FocusGained(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, pulse.Heartbeat), ""
 	serviceMethod := c.serverName + ".FocusGained"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
@@ -47,7 +126,7 @@ func (c *Client) FocusGained(args []string) {
 // OpenFile should be called when a buffer is opened. The server
 // will check if the path is a valid file.
 func (c *Client) OpenFile(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, pulse.Heartbeat), ""
 	serviceMethod := c.serverName + ".OpenFile"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
@@ -57,15 +136,106 @@ func (c *Client) OpenFile(args []string) {
 // Its purpose is to notify the server that the current session remains active.
 // The server ends the session if we don't perform any actions for 10 minutes.
 func (c *Client) SendHeartbeat(args []string) {
-	event, reply := createEvent(args), ""
+	c.sendHeartbeat(createEvent(args, pulse.Heartbeat))
+}
+
+// TextChanged should be called when the text of a buffer is modified.
+func (c *Client) TextChanged(args []string) {
+	c.sendHeartbeat(createEvent(args, pulse.TextChanged))
+}
+
+// CursorMoved should be called when the cursor moves within a buffer.
+func (c *Client) CursorMoved(args []string) {
+	c.sendHeartbeat(createEvent(args, pulse.CursorMoved))
+}
+
+// BufferWritten should be called when a buffer is written to disk.
+func (c *Client) BufferWritten(args []string) {
+	c.sendHeartbeat(createEvent(args, pulse.Write))
+}
+
+// sendHeartbeat sends a heartbeat for an event of a specific type. Heartbeats
+// are throttled per type, unless they're for another file. Writes are counted
+// by the server, which is why each one of them is sent.
+func (c *Client) sendHeartbeat(event pulse.Event) {
+	if event.Type != pulse.Write && c.throttled(event) {
+		return
+	}
+
+	reply := ""
 	serviceMethod := c.serverName + ".SendHeartbeat"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
 }
 
+// throttled reports whether a heartbeat of the same type was sent for the
+// same file recently. Otherwise, the event is recorded as sent.
+func (c *Client) throttled(event pulse.Event) bool {
+	c.sentMu.Lock()
+	defer c.sentMu.Unlock()
+
+	now := time.Now()
+	last, ok := c.lastSent[event.Type]
+	if ok && last.path == event.Path && now.Sub(last.at) < heartbeatThrottle {
+		return true
+	}
+	c.lastSent[event.Type] = sentEvent{path: event.Path, at: now}
+	return false
+}
+
+// Commit should be called by the post-commit hook of a repository.
+func (c *Client) Commit(commit pulse.Commit) error {
+	reply := ""
+	serviceMethod := c.serverName + ".Commit"
+	return c.rpcClient.Call(serviceMethod, commit, &reply)
+}
+
+// Today returns the time that has been tracked today, formatted for the statusline.
+func (c *Client) Today(_ []string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.todayFetched.IsZero() && time.Since(c.todayFetched) < todayTTL {
+		return c.today, nil
+	}
+
+	var summary pulse.Summary
+	serviceMethod := c.serverName + ".Today"
+	err := c.rpcClient.Call(serviceMethod, pulse.Event{}, &summary)
+	if err != nil {
+		//nolint: nilerr // Keep showing the previous value rather than an error in the statusline.
+		return c.today, nil
+	}
+
+	c.today = FormatDuration(time.Duration(summary.TotalTimeMs) * time.Millisecond)
+	c.todayFetched = time.Now()
+	return c.today, nil
+}
+
+// Break returns a reminder to take a break if we've been continuously
+// active for longer than the configured threshold, and an empty string otherwise.
+func (c *Client) Break() (string, error) {
+	var active time.Duration
+	serviceMethod := c.serverName + ".Break"
+	err := c.rpcClient.Call(serviceMethod, pulse.Event{}, &active)
+	if err != nil || active == 0 {
+		return "", err
+	}
+	return fmt.Sprintf("You've been coding for %s. Time to take a break!", FormatDuration(active)), nil
+}
+
+// FormatDuration formats a duration as hours and minutes, e.g. "2h 13m".
+func FormatDuration(d time.Duration) string {
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	if hours == 0 {
+		return fmt.Sprintf("%dm", minutes)
+	}
+	return fmt.Sprintf("%dh %dm", hours, minutes)
+}
+
 // EndSession should be called when the neovim process ends.
 func (c *Client) EndSession(args []string) {
-	event, reply := createEvent(args), ""
+	event, reply := createEvent(args, pulse.Heartbeat), ""
 	serviceMethod := c.serverName + ".EndSession"
 	//nolint: errcheck // I don't want to print eventual errors in the editor.
 	c.rpcClient.Call(serviceMethod, event, &reply)
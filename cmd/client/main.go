@@ -1,18 +1,83 @@
 package main
 
 import (
+	"fmt"
+	"os"
+	"time"
+
 	"github.com/creativecreature/pulse"
 	"github.com/creativecreature/pulse/client"
+	"github.com/creativecreature/pulse/git"
+	"github.com/neovim/go-client/nvim"
 	"github.com/neovim/go-client/nvim/plugin"
 )
 
+// commitHook sends the most recent commit of the repository in
+// the working directory to the server. It's run by the post-commit
+// hook, which is why we print errors rather than panicking. We exit
+// quietly if the server isn't running, which isn't an error.
+func commitHook() {
+	cfg, err := pulse.ParseConfig()
+	if err != nil {
+		return
+	}
+	network, address := cfg.Address()
+	client, err := client.New(cfg.Server.Name, network, address, cfg.Server.Token)
+	if err != nil {
+		return
+	}
+
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pulse:", err)
+		os.Exit(1)
+	}
+
+	commit, err := git.ParseCommit(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pulse: failed to parse the commit:", err)
+		os.Exit(1)
+	}
+
+	err = client.Commit(commit)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "pulse: failed to send the commit:", err)
+		os.Exit(1)
+	}
+}
+
+// breakPollInterval determines how often we ask the server if it's time to take a break.
+const breakPollInterval = time.Minute
+
+// pollBreaks asks the server if it's time to take a break, and shows the reminder in the
+// editor. Reminders can't be shown if the editor is gone, which is when we stop polling.
+func pollBreaks(v *nvim.Nvim, client *client.Client) {
+	ticker := time.NewTicker(breakPollInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		reminder, err := client.Break()
+		if err != nil || reminder == "" {
+			continue
+		}
+		if err := v.Notify(reminder, nvim.LogInfoLevel, nil); err != nil {
+			return
+		}
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "commit" {
+		commitHook()
+		return
+	}
+
 	cfg, err := pulse.ParseConfig()
 	if err != nil {
 		panic("failed to parse config")
 	}
 
-	client, err := client.New(cfg.Server.Name, cfg.Server.Port, cfg.Server.Hostname)
+	network, address := cfg.Address()
+	client, err := client.New(cfg.Server.Name, network, address, cfg.Server.Token)
 	if err != nil {
 		panic(err)
 	}
@@ -21,7 +86,12 @@ func main() {
 		p.HandleFunction(&plugin.FunctionOptions{Name: "OnFocusGained"}, client.FocusGained)
 		p.HandleFunction(&plugin.FunctionOptions{Name: "OpenFile"}, client.OpenFile)
 		p.HandleFunction(&plugin.FunctionOptions{Name: "SendHeartbeat"}, client.SendHeartbeat)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "TextChanged"}, client.TextChanged)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "CursorMoved"}, client.CursorMoved)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "BufferWritten"}, client.BufferWritten)
 		p.HandleFunction(&plugin.FunctionOptions{Name: "EndSession"}, client.EndSession)
+		p.HandleFunction(&plugin.FunctionOptions{Name: "PulseToday"}, client.Today)
+		go pollBreaks(p.Nvim, client)
 		return nil
 	})
 }
@@ -0,0 +1,75 @@
+// Replay recomputes the coding sessions from the event journal. The flags
+// override the accounting rules of the configuration, which lets us see
+// how the sessions would have looked under different rules.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"text/tabwriter"
+	"time"
+
+	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/client"
+	"github.com/creativecreature/pulse/server"
+)
+
+func main() {
+	cfg, err := pulse.ParseConfig()
+	if err != nil {
+		panic("failed to parse config")
+	}
+
+	userHomeDir, err := os.UserHomeDir()
+	if err != nil {
+		panic(err)
+	}
+
+	calendar, err := cfg.Calendar()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay:", err)
+		os.Exit(1)
+	}
+
+	today := calendar.DateString(time.Now())
+	journalDir := flag.String("journal", path.Join(userHomeDir, ".pulse", "segments", server.JournalDir), "the directory of the journal")
+	fromFlag := flag.String("from", today, "the first day to replay")
+	toFlag := flag.String("to", today, "the last day to replay")
+	flag.DurationVar(&cfg.Server.IdleGracePeriod, "idle-grace-period", cfg.Server.IdleGracePeriod, "the time that is counted after the last heartbeat")
+	flag.DurationVar(&cfg.Server.HeartbeatTTL, "heartbeat-ttl", cfg.Server.HeartbeatTTL, "the time without heartbeats before a buffer expires")
+	flag.Parse()
+
+	from, err := calendar.ParseDate(*fromFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay: invalid from date:", err)
+		os.Exit(1)
+	}
+	to, err := calendar.ParseDate(*toFlag)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay: invalid to date:", err)
+		os.Exit(1)
+	}
+
+	entries, err := server.ReadJournal(calendar, *journalDir, from, to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay: failed to read the journal:", err)
+		os.Exit(1)
+	}
+
+	sessions, err := server.Replay(cfg, entries)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "replay: failed to replay the journal:", err)
+		os.Exit(1)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, session := range sessions {
+		fmt.Fprintf(w, "%s\t%s\n", session.DateString, client.FormatDuration(time.Duration(session.TotalTimeMs)*time.Millisecond))
+		for _, repo := range session.Repositories {
+			fmt.Fprintf(w, "  %s\t%s\n", repo.Name, client.FormatDuration(time.Duration(repo.DurationMs)*time.Millisecond))
+		}
+	}
+	w.Flush()
+}
@@ -9,10 +9,14 @@ import (
 	"time"
 
 	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/clock"
 	"github.com/creativecreature/pulse/mongo"
 	"github.com/creativecreature/pulse/server"
 )
 
+// configPollInterval determines how often we check the configuration file for changes.
+const configPollInterval = 10 * time.Second
+
 func main() {
 	cfg, err := pulse.ParseConfig()
 	if err != nil {
@@ -40,11 +44,27 @@ func main() {
 	// Create the path for the log storages segment files.
 	segmentPath := path.Join(userHomeDir, ".pulse", "segments")
 
-	server := server.New(cfg, segmentPath, client)
-	server.RunBackgroundJobs(ctx, cfg.Server.SegmentationInterval)
+	configureClient(client, cfg)
+	server := server.New(cfg, segmentPath, client, server.WithSessionReader(client))
+	server.StartBackgroundJobs(ctx)
+	go pulse.WatchConfig(ctx, pulse.ConfigFile(), clock.New(), configPollInterval, func(cfg *pulse.Config) {
+		configureClient(client, cfg)
+		server.Reload(cfg)
+	})
 
-	err = server.StartServer(ctx, cfg.Server.Port)
+	err = server.StartServer(ctx)
 	if err != nil {
 		panic(err)
 	}
 }
+
+// configureClient applies the parts of the configuration that the client uses
+// when it writes a daily session. The goals are evaluated, and persisted, each
+// time a session is written. An invalid calendar is logged by the server, and
+// the client keeps using the previous one, just like the server does.
+func configureClient(client *mongo.Client, cfg *pulse.Config) {
+	client.SetGoals(cfg.Goals)
+	if calendar, err := cfg.Calendar(); err == nil {
+		client.SetCalendar(calendar)
+	}
+}
@@ -0,0 +1,39 @@
+package pulse
+
+import (
+	"sort"
+	"time"
+)
+
+// Commit represents a commit that was made to a repository. The duration is the
+// time that was spent on the committed files since they were last committed.
+type Commit struct {
+	SHA         string    `bson:"sha"          json:"sha"`
+	Repository  string    `bson:"repository"   json:"repository"`
+	Branch      string    `bson:"branch"       json:"branch"`
+	CommittedAt time.Time `bson:"committed_at" json:"committed_at"`
+	Files       []string  `bson:"files"        json:"files"`
+	DurationMs  int64     `bson:"duration_ms"  json:"duration_ms"`
+}
+
+// Commits represents a slice of commits.
+type Commits []Commit
+
+// merge takes two slices of commits, merges them, and returns the result
+// ordered by the time of the commit. Commits are unique by their hash.
+func (a Commits) merge(b Commits) Commits {
+	seen := make(map[string]bool, len(a)+len(b))
+	mergedCommits := make(Commits, 0, len(a)+len(b))
+	for _, commit := range append(append(Commits{}, a...), b...) {
+		if seen[commit.SHA] {
+			continue
+		}
+		seen[commit.SHA] = true
+		mergedCommits = append(mergedCommits, commit)
+	}
+
+	sort.Slice(mergedCommits, func(i, j int) bool {
+		return mergedCommits[i].CommittedAt.Before(mergedCommits[j].CommittedAt)
+	})
+	return mergedCommits
+}
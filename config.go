@@ -1,25 +1,70 @@
 package pulse
 
 import (
+	"cmp"
+	"context"
+	"fmt"
+	"net"
+	"os"
 	"time"
 
+	"github.com/creativecreature/pulse/clock"
 	"github.com/spf13/viper"
 )
 
+// TokenHeader is the header that the client uses to present
+// the token from the configuration when it connects to the server.
+const TokenHeader = "X-Pulse-Token"
+
 type Config struct {
 	Server struct {
 		Name                 string
 		Hostname             string
 		Port                 string
+		Socket               string
+		Token                string
+		LogLevel             string
 		AggregationInterval  time.Duration
 		SegmentationInterval time.Duration
+		HeartbeatInterval    time.Duration
+		HeartbeatTTL         time.Duration
 		SegmentSizeKB        int
+		IdleGracePeriod      time.Duration
+		JournalDays          int
+	}
+	Breaks struct {
+		Threshold time.Duration
+		Gap       time.Duration
+	}
+	Tickets struct {
+		Patterns []string
+	}
+	Ignore struct {
+		Repositories []string
+		Paths        []string
+		Filetypes    []string
+	}
+	Projects struct {
+		Markers []string
+		Paths   []string
+	}
+	Privacy struct {
+		Secret string
+		Rules  []RedactionRule
 	}
 	Database struct {
 		Name       string
 		URI        string
 		Collection string
 	}
+	Workspaces []Workspace
+	Goals      []Goal
+	// Timezone is the IANA name of the timezone, e.g. Europe/Stockholm, that
+	// the time is bucketed into days in. It defaults to the local timezone.
+	Timezone string
+	// DayStart is the time of day, e.g. 4h, at which a new day starts. The
+	// work that is done before it counts towards the previous day.
+	DayStart time.Duration
 }
 
 func ParseConfig() (*Config, error) {
@@ -35,3 +80,97 @@ func ParseConfig() (*Config, error) {
 	err = viper.Unmarshal(&cfg)
 	return &cfg, err
 }
+
+// defaultHostname is used if no hostname has been configured. We don't want
+// to accept connections from other machines unless we've been told to.
+const defaultHostname = "localhost"
+
+// Address returns the network and address that the server listens on. A unix
+// socket takes precedence over the hostname and port if it has been configured.
+func (c *Config) Address() (network, address string) {
+	if c.Server.Socket != "" {
+		return "unix", os.ExpandEnv(c.Server.Socket)
+	}
+	return "tcp", net.JoinHostPort(cmp.Or(c.Server.Hostname, defaultHostname), c.Server.Port)
+}
+
+// Location returns the configured timezone, or the local timezone if none has been configured.
+func (c *Config) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+// StartOfDay returns the configured day start. It has to be within the first 24 hours of the day.
+func (c *Config) StartOfDay() (time.Duration, error) {
+	if c.DayStart < 0 || c.DayStart >= 24*time.Hour {
+		return 0, fmt.Errorf("invalid day start %s", c.DayStart)
+	}
+	return c.DayStart, nil
+}
+
+// Calendar returns the calendar of the configured timezone and start of the day.
+func (c *Config) Calendar() (Calendar, error) {
+	location, err := c.Location()
+	if err != nil {
+		return Calendar{}, fmt.Errorf("invalid timezone: %w", err)
+	}
+	dayStart, err := c.StartOfDay()
+	if err != nil {
+		return Calendar{}, err
+	}
+	return Calendar{Location: location, DayStart: dayStart}, nil
+}
+
+// ConfigFile returns the path of the configuration file that was parsed.
+func ConfigFile() string {
+	return viper.ConfigFileUsed()
+}
+
+// readConfigFile parses the configuration file at the given path.
+func readConfigFile(path string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	err := v.ReadInConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	err = v.Unmarshal(&cfg)
+	return &cfg, err
+}
+
+// WatchConfig checks the configuration file for modifications each time the
+// interval elapses on the clock. Whenever the file has changed, it's parsed
+// again and passed to onChange. It blocks until the context is cancelled.
+func WatchConfig(ctx context.Context, path string, c clock.Clock, interval time.Duration, onChange func(*Config)) {
+	var lastModified time.Time
+	if info, err := os.Stat(path); err == nil {
+		lastModified = info.ModTime()
+	}
+
+	logger := NewLogger()
+	ticker, stopTicker := c.NewTicker(interval)
+	defer stopTicker()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker:
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(lastModified) {
+				continue
+			}
+			lastModified = info.ModTime()
+
+			cfg, err := readConfigFile(path)
+			if err != nil {
+				logger.Error("Failed to reload the configuration", "path", path, "err", err)
+				continue
+			}
+			onChange(cfg)
+		}
+	}
+}
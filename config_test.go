@@ -0,0 +1,52 @@
+package pulse_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/clock"
+)
+
+func TestWatchConfig(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("server:\n  aggregationInterval: 10m\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockClock := clock.NewMock(time.Now())
+	changes := make(chan *pulse.Config, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go pulse.WatchConfig(ctx, path, mockClock, time.Second, func(cfg *pulse.Config) {
+		changes <- cfg
+	})
+	time.Sleep(100 * time.Millisecond)
+
+	err = os.WriteFile(path, []byte("server:\n  aggregationInterval: 1m\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Make sure that the modification time changes on file systems with a coarse resolution.
+	modified := time.Now().Add(time.Minute)
+	err = os.Chtimes(path, modified, modified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockClock.Add(time.Second)
+
+	select {
+	case cfg := <-changes:
+		if cfg.Server.AggregationInterval != time.Minute {
+			t.Errorf("expected the aggregation interval to be %s; got %s", time.Minute, cfg.Server.AggregationInterval)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the configuration to be reloaded")
+	}
+}
@@ -0,0 +1,92 @@
+package pulse
+
+import "strings"
+
+// maxEdits bounds the amount of work we're willing to do when diffing a
+// file. Files that were rewritten beyond this point are compared by
+// counting the lines that appear more often in one version than the other.
+const maxEdits = 4096
+
+// DiffLines compares two versions of a file and returns the number
+// of lines that were added and removed to get from before to after.
+func DiffLines(before, after []byte) (added, removed int) {
+	a, b := splitLines(before), splitLines(after)
+
+	// Edits are usually made somewhere in the middle of the file. Trimming
+	// the common prefix and suffix keeps the search space small.
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		a, b = a[1:], b[1:]
+	}
+	for len(a) > 0 && len(b) > 0 && a[len(a)-1] == b[len(b)-1] {
+		a, b = a[:len(a)-1], b[:len(b)-1]
+	}
+
+	edits, ok := shortestEdit(a, b)
+	if !ok {
+		return countUnmatched(a, b)
+	}
+
+	// The shortest edit consists of the lines that are unique to each version.
+	// Their difference equals the difference in the number of lines.
+	added = (edits + len(b) - len(a)) / 2
+	removed = (edits - len(b) + len(a)) / 2
+	return added, removed
+}
+
+// splitLines splits the content of a file into lines.
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+}
+
+// shortestEdit uses Myers' algorithm to find the length of the shortest
+// edit script, which is the number of lines that were inserted or deleted.
+// It gives up once the number of edits exceeds maxEdits.
+func shortestEdit(a, b []string) (int, bool) {
+	n, m := len(a), len(b)
+	limit := min(n+m, maxEdits)
+	offset := limit + 1
+	v := make([]int, 2*limit+3)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return d, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// countUnmatched approximates a diff by comparing how many times
+// each line occurs in the two versions of the file.
+func countUnmatched(a, b []string) (added, removed int) {
+	occurrences := make(map[string]int, len(a))
+	for _, line := range a {
+		occurrences[line]++
+	}
+	for _, line := range b {
+		if occurrences[line] > 0 {
+			occurrences[line]--
+			continue
+		}
+		added++
+	}
+	for _, count := range occurrences {
+		removed += count
+	}
+	return added, removed
+}
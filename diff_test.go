@@ -0,0 +1,45 @@
+package pulse_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/creativecreature/pulse"
+)
+
+type diffTest struct {
+	name            string
+	before          string
+	after           string
+	expectedAdded   int
+	expectedRemoved int
+}
+
+func TestDiffLines(t *testing.T) {
+	t.Parallel()
+
+	testCases := []diffTest{
+		{"unchanged", "a\nb\nc\n", "a\nb\nc\n", 0, 0},
+		{"empty", "", "", 0, 0},
+		{"new file", "", "a\nb\n", 2, 0},
+		{"emptied file", "a\nb\n", "", 0, 2},
+		{"appended", "a\nb\n", "a\nb\nc\nd\n", 2, 0},
+		{"inserted", "a\nc\n", "a\nb\nc\n", 1, 0},
+		{"removed", "a\nb\nc\n", "a\nc\n", 0, 1},
+		{"modified", "a\nb\nc\n", "a\nB\nc\n", 1, 1},
+		{"moved", "a\nb\nc\nd\n", "b\nc\nd\na\n", 1, 1},
+		{"missing newline", "a\nb", "a\nb\nc", 1, 0},
+		{"rewritten", strings.Repeat("a\n", 5000), strings.Repeat("b\n", 5000), 5000, 5000},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			added, removed := pulse.DiffLines([]byte(tc.before), []byte(tc.after))
+			if added != tc.expectedAdded || removed != tc.expectedRemoved {
+				t.Errorf("expected +%d -%d, got +%d -%d", tc.expectedAdded, tc.expectedRemoved, added, removed)
+			}
+		})
+	}
+}
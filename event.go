@@ -1,9 +1,24 @@
 package pulse
 
+// EventType describes the editor activity that triggered an event.
+type EventType int8
+
+const (
+	// Heartbeat signals activity without any further details.
+	Heartbeat EventType = iota
+	// TextChanged signals that the text of the buffer was modified.
+	TextChanged
+	// CursorMoved signals that the cursor moved within the buffer.
+	CursorMoved
+	// Write signals that the buffer was written to disk.
+	Write
+)
+
 // Event represents the events we receive from the editor.
 type Event struct {
-	EditorID string
-	Path     string
-	Editor   string
-	OS       string
+	EditorID string    `json:"editor_id,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Editor   string    `json:"editor,omitempty"`
+	OS       string    `json:"os,omitempty"`
+	Type     EventType `json:"type,omitempty"`
 }
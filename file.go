@@ -7,25 +7,45 @@ type GitFile struct {
 	Name       string
 	Filetype   string
 	Repository string
+	Branch     string
 	Path       string
+	// Root is the absolute path of the repository's root directory.
+	Root string
+	// Workspace is true if the file isn't under source control,
+	// and belongs to one of the configured workspaces instead.
+	Workspace bool
+	// Project is the path of the sub-project within the repository, if any.
+	Project string
+	// Config holds the overrides from the repository's configuration file.
+	Config RepositoryConfig
 }
 
 // File represents a file that has been aggregated
 // for a given time period (day, week, month, year).
 type File struct {
-	Name       string `bson:"name"`
-	Path       string `bson:"path"`
-	Filetype   string `bson:"filetype"`
-	DurationMs int64  `bson:"duration_ms"`
+	Name         string `bson:"name"`
+	Path         string `bson:"path"`
+	Filetype     string `bson:"filetype"`
+	DurationMs   int64  `bson:"duration_ms"`
+	EditingMs    int64  `bson:"editing_ms"`
+	ReadingMs    int64  `bson:"reading_ms"`
+	Writes       int    `bson:"writes"`
+	LinesAdded   int    `bson:"lines_added"`
+	LinesRemoved int    `bson:"lines_removed"`
 }
 
 // merge takes two files, merges them, and returns the result.
 func (a File) merge(b File) File {
 	return File{
-		Name:       cmp.Or(a.Name, b.Name),
-		Path:       cmp.Or(a.Path, b.Path),
-		Filetype:   cmp.Or(a.Filetype, b.Filetype),
-		DurationMs: a.DurationMs + b.DurationMs,
+		Name:         cmp.Or(a.Name, b.Name),
+		Path:         cmp.Or(a.Path, b.Path),
+		Filetype:     cmp.Or(a.Filetype, b.Filetype),
+		DurationMs:   a.DurationMs + b.DurationMs,
+		EditingMs:    a.EditingMs + b.EditingMs,
+		ReadingMs:    a.ReadingMs + b.ReadingMs,
+		Writes:       a.Writes + b.Writes,
+		LinesAdded:   a.LinesAdded + b.LinesAdded,
+		LinesRemoved: a.LinesRemoved + b.LinesRemoved,
 	}
 }
 
@@ -0,0 +1,71 @@
+package git
+
+import (
+	"cmp"
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+var ErrParseCommit = errors.New("failed to parse the commit")
+
+// ParseCommit returns the most recent commit of the repository that the
+// directory belongs to. The paths of the files that were changed are
+// prefixed with the repository name, and are relative to the worktree,
+// just like the paths of our buffers.
+func (f FileParser) ParseCommit(dir string) (pulse.Commit, error) {
+	dirs, err := f.findGitFolder(dir)
+	if err != nil {
+		return pulse.Commit{}, err
+	}
+
+	repositoryName, err := f.extractRepositoryName(dirs.common)
+	if err != nil {
+		return pulse.Commit{}, err
+	}
+
+	// The files must be named like our buffers, or we won't find their time.
+	config := f.repositoryConfig(dirs.worktree)
+	repositoryName = cmp.Or(config.Name, repositoryName)
+
+	// The first two lines are the hash and timestamp, followed by the changed files.
+	output, err := exec.Command("git", "-C", dir, "show", "--format=%H%n%ct", "--name-only", "HEAD").Output()
+	if err != nil {
+		return pulse.Commit{}, err
+	}
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	if len(lines) < 2 {
+		return pulse.Commit{}, ErrParseCommit
+	}
+
+	timestamp, err := strconv.ParseInt(lines[1], 10, 64)
+	if err != nil {
+		return pulse.Commit{}, ErrParseCommit
+	}
+
+	files := make([]string, 0, len(lines)-2)
+	for _, line := range lines[2:] {
+		if line == "" {
+			continue
+		}
+		files = append(files, repositoryName+"/"+line)
+	}
+
+	commit := pulse.Commit{
+		SHA:         lines[0],
+		Repository:  repositoryName,
+		Branch:      f.extractBranch(dirs.head),
+		CommittedAt: time.Unix(timestamp, 0),
+		Files:       files,
+	}
+
+	return commit, nil
+}
+
+func ParseCommit(dir string) (pulse.Commit, error) {
+	return New().ParseCommit(dir)
+}
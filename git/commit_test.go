@@ -0,0 +1,130 @@
+package git_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/creativecreature/pulse/git"
+)
+
+// runGit runs a git command in the directory.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=pulse", "GIT_AUTHOR_EMAIL=pulse@example.com",
+		"GIT_COMMITTER_NAME=pulse", "GIT_COMMITTER_EMAIL=pulse@example.com",
+	)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v: %v\n%s", args, err, output)
+	}
+}
+
+func TestParseCommit(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	run := func(args ...string) {
+		t.Helper()
+		runGit(t, dir, args...)
+	}
+
+	run("init", "--initial-branch=main")
+	run("remote", "add", "origin", "git@github.com:creativecreature/pulse.git")
+	err := os.MkdirAll(filepath.Join(dir, "cmd"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"main.go", "cmd/main.go"} {
+		err = os.WriteFile(filepath.Join(dir, name), []byte("package main\n"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	run("add", ".")
+	run("commit", "-m", "Initial commit")
+
+	commit, err := git.ParseCommit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.Repository != "pulse" {
+		t.Errorf("expected the repository to be pulse; got %s", commit.Repository)
+	}
+	if commit.Branch != "main" {
+		t.Errorf("expected the branch to be main; got %s", commit.Branch)
+	}
+	if len(commit.SHA) != 40 {
+		t.Errorf("expected a full commit hash; got %s", commit.SHA)
+	}
+	expectedFiles := []string{"pulse/cmd/main.go", "pulse/main.go"}
+	if !slices.Equal(commit.Files, expectedFiles) {
+		t.Errorf("expected the files to be %v; got %v", expectedFiles, commit.Files)
+	}
+}
+
+func TestParseCommitInWorktree(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// A bare clone in .bare, with the main branch checked out in a worktree next to it.
+	// Git resolves the symbolic links in the paths of the worktrees, e.g. /var on macOS.
+	source, project := t.TempDir(), t.TempDir()
+	project, err := filepath.EvalSymlinks(project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, source, "init", "--initial-branch=main")
+	err = os.WriteFile(filepath.Join(source, "README.md"), []byte("# pulse\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, source, "add", ".")
+	runGit(t, source, "commit", "-m", "Initial commit")
+	runGit(t, project, "clone", "--bare", source, ".bare")
+	runGit(t, project, "--git-dir=.bare", "remote", "set-url", "origin", "git@github.com:conner/pulse-fork.git")
+	runGit(t, project, "--git-dir=.bare", "worktree", "add", filepath.Join(project, "main"), "main")
+
+	worktree := filepath.Join(project, "main")
+	files := map[string]string{
+		".pulse.yaml": "name: renamed\n",
+		"a.go":        "package main\n",
+	}
+	for name, content := range files {
+		err = os.WriteFile(filepath.Join(worktree, name), []byte(content), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	runGit(t, worktree, "add", ".")
+	runGit(t, worktree, "commit", "-m", "Add a.go")
+
+	file, err := git.ParseFile(filepath.Join(worktree, "a.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := git.ParseCommit(worktree)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Repository != "renamed" || commit.Repository != "renamed" {
+		t.Errorf("expected both repositories to be renamed; got %s and %s", file.Repository, commit.Repository)
+	}
+	if file.Root != worktree {
+		t.Errorf("expected the root to be %s; got %s", worktree, file.Root)
+	}
+	if !slices.Contains(commit.Files, file.Path) {
+		t.Errorf("expected the commit to include the file %s; got %v", file.Path, commit.Files)
+	}
+}
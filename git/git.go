@@ -1,21 +1,28 @@
 package git
 
 import (
+	"cmp"
 	"errors"
 	"fmt"
 	"io/fs"
 	"path"
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	"github.com/creativecreature/pulse"
 )
 
 var (
+	gitDirExp      = regexp.MustCompile("gitdir: (?P<GitDir>.*)")
 	bareRepoExp    = regexp.MustCompile("gitdir: (?P<GitDir>.*)/worktrees")
 	regularRepoExp = regexp.MustCompile(`url = .*(?:/|:)(?P<RepoName>[^/]*?)\.git`)
 )
 
+// detachedHeadLength is the number of characters of the commit hash
+// that we use as the branch name when the HEAD is detached.
+const detachedHeadLength = 7
+
 var (
 	ErrEmptyPath         = errors.New("path is empty string")
 	ErrPathNotAFile      = errors.New("the path is not a file")
@@ -34,11 +41,23 @@ type Reader interface {
 
 type FileParser struct {
 	Reader Reader
+	// Projects determine where the sub-projects of a repository
+	// begin. No projects are detected if there aren't any rules.
+	Projects pulse.ProjectRules
+}
+
+// gitDirs holds the directories that git uses for a worktree. The
+// HEAD is specific to each worktree, while the config is shared.
+// The worktree is the directory that holds the checked out files.
+type gitDirs struct {
+	head     string
+	common   string
+	worktree string
 }
 
 // New creates a new FileParser.
 func New() FileParser {
-	return FileParser{filereader{}}
+	return FileParser{Reader: filereader{}}
 }
 
 // extractSubExp extracts a named subgroup from a regexp match.
@@ -51,34 +70,38 @@ func extractSubExp(re *regexp.Regexp, matches []string, subexp string) string {
 	return exp
 }
 
-// extractRepositoryName extracts the name of the repository from a .git file.
-func (f FileParser) extractBareRepositoryPath(filepath string) (string, error) {
+// extractBareRepositoryPath extracts the git directories from a .git file.
+func (f FileParser) extractBareRepositoryPath(filepath string) (gitDirs, error) {
 	fileContent, err := f.Reader.ReadFile(filepath)
 	if err != nil {
-		return "", err
+		return gitDirs{}, err
 	}
 	matches := bareRepoExp.FindStringSubmatch(string(fileContent))
 
 	if len(matches) == 0 {
-		return "", ErrParseBareRepoPath
+		return gitDirs{}, ErrParseBareRepoPath
 	}
 
-	return extractSubExp(bareRepoExp, matches, "GitDir"), nil
+	headMatches := gitDirExp.FindStringSubmatch(string(fileContent))
+	return gitDirs{
+		head:   strings.TrimSpace(extractSubExp(gitDirExp, headMatches, "GitDir")),
+		common: extractSubExp(bareRepoExp, matches, "GitDir"),
+	}, nil
 }
 
 // findGitFolder calls itself recursively until it finds a .git
 // configuration or reaches the root of the filesystem. It returns
-// the name of the repository by parsing the url of the origin.
-func (f FileParser) findGitFolder(dir string) (string, error) {
+// the directories that hold the HEAD and config of the repository.
+func (f FileParser) findGitFolder(dir string) (gitDirs, error) {
 	// Stop the recursion if we have reached the root.
 	if dir == "/" {
-		return "", ErrReachedRoot
+		return gitDirs{}, ErrReachedRoot
 	}
 
 	// Read the directory entries.
 	entries, err := f.Reader.ReadDir(dir)
 	if err != nil {
-		return "", err
+		return gitDirs{}, err
 	}
 
 	// Check if any of the entries is the .git file/folder.
@@ -87,9 +110,12 @@ func (f FileParser) findGitFolder(dir string) (string, error) {
 			// When I work on projects with long-lived branches I use worktrees. If that
 			// is the case the .git file will point to the path of the bare directory.
 			if !e.IsDir() {
-				return f.extractBareRepositoryPath(path.Join(dir, ".git"))
+				dirs, err := f.extractBareRepositoryPath(path.Join(dir, ".git"))
+				dirs.worktree = dir
+				return dirs, err
 			}
-			return path.Join(dir, ".git"), nil
+			gitDir := path.Join(dir, ".git")
+			return gitDirs{head: gitDir, common: gitDir, worktree: dir}, nil
 		}
 	}
 
@@ -114,6 +140,26 @@ func (f FileParser) extractRepositoryName(dirPath string) (string, error) {
 	return extractSubExp(regularRepoExp, matches, "RepoName"), nil
 }
 
+// extractBranch resolves the name of the branch that is checked out from the
+// HEAD. If the HEAD is detached, the abbreviated commit hash is used instead.
+// We return an empty string if the HEAD can't be read.
+func (f FileParser) extractBranch(dirPath string) string {
+	fileContent, err := f.Reader.ReadFile(path.Join(dirPath, "HEAD"))
+	if err != nil {
+		return ""
+	}
+
+	head := strings.TrimSpace(string(fileContent))
+	if ref, ok := strings.CutPrefix(head, "ref: "); ok {
+		return strings.TrimPrefix(ref, "refs/heads/")
+	}
+
+	if len(head) > detachedHeadLength {
+		return head[:detachedHeadLength]
+	}
+	return head
+}
+
 // ParseFile returns a ParseFile struct from an absolute path. It will return an
 // error if the path is empty, if the path is not a file or if it can't find
 // a parent .git file or folder before it reaches the root of the file tree.
@@ -128,18 +174,23 @@ func (f FileParser) ParseFile(absolutePath string) (pulse.GitFile, error) {
 	}
 
 	// Check if the file is under source control.
-	gitFolderPath, err := f.findGitFolder(f.Reader.Dir(absolutePath))
+	dirs, err := f.findGitFolder(f.Reader.Dir(absolutePath))
 	if err != nil {
 		return pulse.GitFile{}, err
 	}
 
-	repositoryName, err := f.extractRepositoryName(gitFolderPath)
+	repositoryName, err := f.extractRepositoryName(dirs.common)
 	if err != nil {
 		return pulse.GitFile{}, err
 	}
 
-	pathFromGitFolder := absolutePath[len(gitFolderPath)-len(".git"):]
-	path := fmt.Sprintf("%s/%s", repositoryName, pathFromGitFolder)
+	// The root is the directory with the checked out files, which isn't
+	// next to the git directory in a worktree of a bare repository. The
+	// repository can override the name that we parsed from the remote.
+	root := dirs.worktree
+	config := f.repositoryConfig(root)
+	repositoryName = cmp.Or(config.Name, repositoryName)
+	path := fmt.Sprintf("%s/%s", repositoryName, strings.TrimPrefix(absolutePath, root+"/"))
 
 	// Tries to get the filetype from either the file extension or name.
 	filename := filepath.Base(absolutePath)
@@ -152,12 +203,44 @@ func (f FileParser) ParseFile(absolutePath string) (pulse.GitFile, error) {
 		Name:       filename,
 		Filetype:   ft,
 		Repository: repositoryName,
+		Branch:     f.extractBranch(dirs.head),
 		Path:       path,
+		Root:       root,
+		Config:     config,
+		Project:    f.detectProject(dirs.worktree, absolutePath),
 	}
 
 	return gitFile, nil
 }
 
+// detectProject returns the path of the innermost sub-project that contains
+// the file, relative to the root. An empty string is returned for files that
+// don't belong to a sub-project, and the root itself is never a sub-project.
+func (f FileParser) detectProject(root, absolutePath string) string {
+	if !f.Projects.Enabled() {
+		return ""
+	}
+
+	for dir := filepath.Dir(absolutePath); strings.HasPrefix(dir, root+"/"); dir = filepath.Dir(dir) {
+		entries, err := f.Reader.ReadDir(dir)
+		if err != nil {
+			return ""
+		}
+
+		filenames := make([]string, 0, len(entries))
+		for _, e := range entries {
+			if !e.IsDir() {
+				filenames = append(filenames, e.Name())
+			}
+		}
+		relativeDir := strings.TrimPrefix(dir, root+"/")
+		if f.Projects.IsProject(relativeDir, filenames) {
+			return relativeDir
+		}
+	}
+	return ""
+}
+
 func ParseFile(absolutePath string) (pulse.GitFile, error) {
 	return New().ParseFile(absolutePath)
 }
@@ -298,6 +298,11 @@ func TestPathInBareProject(t *testing.T) {
 	if got != expected {
 		t.Errorf("GetRepositoryFromPath(%s) = %s; expected %s", path, got, expected)
 	}
+
+	// The path is relative to the worktree, rather than the bare directory.
+	if file.Path != "ore-ui/src/index.ts" {
+		t.Errorf("expected the path ore-ui/src/index.ts; got %s", file.Path)
+	}
 }
 
 func TestPathInProject(t *testing.T) {
@@ -374,3 +379,111 @@ func TestPathInProject(t *testing.T) {
 		t.Errorf("GetRepositoryFromPath(%s) = %s; expected %s", path, got, expected)
 	}
 }
+
+func TestBranch(t *testing.T) {
+	t.Parallel()
+
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:creativecreature/dotfiles.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+
+	testCases := []struct {
+		name     string
+		head     string
+		expected string
+	}{
+		{"branch", "ref: refs/heads/feat/PROJ-1234-some-title\n", "feat/PROJ-1234-some-title"},
+		{"detached", "4b825dc642cb6eb9a060e54bf8d69288fbee4904\n", "4b825dc"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			fileSystemMock := readerMock{
+				Directories: []string{
+					"/Users/conner/code/dotfiles",
+					"/Users/conner/code",
+					"/Users/conner",
+					"/Users",
+					"/",
+				},
+				Entries: map[string][]fs.DirEntry{
+					"/Users/conner/code/dotfiles": {
+						newFileEntry("install.sh", false),
+						newFileEntry(".git", true),
+					},
+				},
+				FileContents: map[string][]byte{
+					"/Users/conner/code/dotfiles/.git/config": []byte(gitConfigFile),
+					"/Users/conner/code/dotfiles/.git/HEAD":   []byte(tc.head),
+				},
+			}
+
+			f := git.New()
+			f.Reader = &fileSystemMock
+
+			path := "/Users/conner/code/dotfiles/install.sh"
+			file, err := f.ParseFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Branch != tc.expected {
+				t.Errorf("ParseFile(%s).Branch = %s; expected %s", path, file.Branch, tc.expected)
+			}
+		})
+	}
+}
+
+func TestBranchInWorktree(t *testing.T) {
+	t.Parallel()
+
+	// Each worktree has its own HEAD within the bare repository.
+	gitFile := "gitdir: /Users/conner/code/ore-ui/.bare/worktrees/dev\n"
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:Mojang/ore-ui.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+
+	fileSystemMock := readerMock{
+		Directories: []string{
+			"/Users/conner/code/ore-ui/dev",
+			"/Users/conner/code/ore-ui",
+			"/Users/conner/code",
+			"/Users/conner",
+			"/Users",
+			"/",
+		},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/code/ore-ui/dev": {
+				newFileEntry("index.ts", false),
+				newFileEntry(".git", false),
+			},
+		},
+		FileContents: map[string][]byte{
+			"/Users/conner/code/ore-ui/dev/.git":                 []byte(gitFile),
+			"/Users/conner/code/ore-ui/.bare/config":             []byte(gitConfigFile),
+			"/Users/conner/code/ore-ui/.bare/HEAD":               []byte("ref: refs/heads/main\n"),
+			"/Users/conner/code/ore-ui/.bare/worktrees/dev/HEAD": []byte("ref: refs/heads/dev\n"),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+
+	path := "/Users/conner/code/ore-ui/dev/index.ts"
+	file, err := f.ParseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Repository != "ore-ui" {
+		t.Errorf("ParseFile(%s).Repository = %s; expected ore-ui", path, file.Repository)
+	}
+	if file.Branch != "dev" {
+		t.Errorf("ParseFile(%s).Branch = %s; expected dev", path, file.Branch)
+	}
+}
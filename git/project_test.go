@@ -0,0 +1,111 @@
+package git_test
+
+import (
+	"io/fs"
+	"testing"
+
+	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/git"
+)
+
+func TestDetectProject(t *testing.T) {
+	t.Parallel()
+
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:conner/monorepo.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+	directoryEntries := map[string][]fs.DirEntry{
+		"/Users/conner/code/monorepo/services/billing/internal": {
+			newFileEntry("invoice.go", false),
+		},
+		"/Users/conner/code/monorepo/services/billing": {
+			newFileEntry("internal", true),
+			newFileEntry("go.mod", false),
+		},
+		"/Users/conner/code/monorepo/services": {
+			newFileEntry("billing", true),
+		},
+		"/Users/conner/code/monorepo/web/app": {
+			newFileEntry("index.ts", false),
+		},
+		"/Users/conner/code/monorepo/web": {
+			newFileEntry("app", true),
+		},
+		"/Users/conner/code/monorepo": {
+			newFileEntry("services", true),
+			newFileEntry("web", true),
+			newFileEntry("go.mod", false),
+			newFileEntry("main.go", false),
+			newFileEntry(".git", true),
+		},
+	}
+
+	testCases := []struct {
+		name            string
+		path            string
+		directories     []string
+		expectedProject string
+	}{
+		{
+			name: "marker file",
+			path: "/Users/conner/code/monorepo/services/billing/internal/invoice.go",
+			directories: []string{
+				"/Users/conner/code/monorepo/services/billing/internal",
+				"/Users/conner/code/monorepo/services/billing",
+				"/Users/conner/code/monorepo/services",
+				"/Users/conner/code/monorepo",
+			},
+			expectedProject: "services/billing",
+		},
+		{
+			name: "path pattern",
+			path: "/Users/conner/code/monorepo/web/app/index.ts",
+			directories: []string{
+				"/Users/conner/code/monorepo/web/app",
+				"/Users/conner/code/monorepo/web",
+				"/Users/conner/code/monorepo",
+			},
+			expectedProject: "web/app",
+		},
+		{
+			name: "root is not a project",
+			path: "/Users/conner/code/monorepo/main.go",
+			directories: []string{
+				"/Users/conner/code/monorepo",
+			},
+			expectedProject: "",
+		},
+	}
+
+	rules, err := pulse.NewProjectRules([]string{"go.mod", "package.json"}, []string{"web/*"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := git.New()
+			f.Projects = rules
+			f.Reader = &readerMock{
+				Directories: tc.directories,
+				Entries:     directoryEntries,
+				FileContents: map[string][]byte{
+					"/Users/conner/code/monorepo/.git/config": []byte(gitConfigFile),
+				},
+			}
+
+			file, err := f.ParseFile(tc.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if file.Project != tc.expectedProject {
+				t.Errorf("expected the project %q, got %q", tc.expectedProject, file.Project)
+			}
+		})
+	}
+}
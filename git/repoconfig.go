@@ -0,0 +1,45 @@
+package git
+
+import (
+	"bytes"
+	"path/filepath"
+	"sync"
+
+	"github.com/creativecreature/pulse"
+)
+
+// cachedConfig is a parsed configuration file, and the content it was parsed from.
+type cachedConfig struct {
+	content []byte
+	config  pulse.RepositoryConfig
+}
+
+// repositoryConfigs caches the configuration files by the root of their
+// repository. Files are only parsed again if their content has changed.
+var repositoryConfigs = struct {
+	sync.Mutex
+	byRoot map[string]cachedConfig
+}{byRoot: make(map[string]cachedConfig)}
+
+// repositoryConfig returns the overrides from the configuration file at the
+// root of the repository. A repository without a valid file has no overrides,
+// since a malformed file shouldn't stop the repository from being tracked.
+func (f FileParser) repositoryConfig(root string) pulse.RepositoryConfig {
+	content, err := f.Reader.ReadFile(filepath.Join(root, pulse.RepositoryConfigFilename))
+	if err != nil {
+		return pulse.RepositoryConfig{}
+	}
+
+	repositoryConfigs.Lock()
+	defer repositoryConfigs.Unlock()
+	if cached, ok := repositoryConfigs.byRoot[root]; ok && bytes.Equal(cached.content, content) {
+		return cached.config
+	}
+
+	config, err := pulse.ParseRepositoryConfig(content)
+	if err != nil {
+		config = pulse.RepositoryConfig{}
+	}
+	repositoryConfigs.byRoot[root] = cachedConfig{content: content, config: config}
+	return config
+}
@@ -0,0 +1,128 @@
+package git_test
+
+import (
+	"io/fs"
+	"slices"
+	"testing"
+
+	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/git"
+)
+
+func TestRepositoryConfigOverrides(t *testing.T) {
+	t.Parallel()
+
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:conner/pulse-fork.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+	pulseConfigFile := `
+name: pulse
+category: oss
+ignore:
+  - "vendor/"
+privacy: collapse
+depth: 1
+`
+
+	fileSystemMock := readerMock{
+		Directories: []string{
+			"/Users/conner/code/pulse-fork/server",
+			"/Users/conner/code/pulse-fork",
+		},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/code/pulse-fork/server": {
+				newFileEntry("server.go", false),
+			},
+			"/Users/conner/code/pulse-fork": {
+				newFileEntry("server", true),
+				newFileEntry(".git", true),
+				newFileEntry(".pulse.yaml", false),
+			},
+		},
+		FileContents: map[string][]byte{
+			"/Users/conner/code/pulse-fork/.git/config": []byte(gitConfigFile),
+			"/Users/conner/code/pulse-fork/.pulse.yaml": []byte(pulseConfigFile),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+
+	file, err := f.ParseFile("/Users/conner/code/pulse-fork/server/server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if file.Repository != "pulse" {
+		t.Errorf("expected the repository pulse, got %s", file.Repository)
+	}
+	if file.Path != "pulse/server/server.go" {
+		t.Errorf("expected the path pulse/server/server.go, got %s", file.Path)
+	}
+	if file.Config.Category != "oss" {
+		t.Errorf("expected the category oss, got %s", file.Config.Category)
+	}
+	if !slices.Equal(file.Config.Ignore, []string{"vendor/"}) {
+		t.Errorf("expected the ignored paths [vendor/], got %v", file.Config.Ignore)
+	}
+
+	expectedRule := pulse.RedactionRule{Repository: "pulse", Mode: pulse.RedactCollapse, Depth: 1}
+	rule, ok := file.Config.RedactionRule(file.Repository)
+	if !ok || *rule != expectedRule {
+		t.Errorf("expected the redaction rule %v, got %v", expectedRule, rule)
+	}
+}
+
+func TestRepositoryConfigInWorktree(t *testing.T) {
+	t.Parallel()
+
+	// The configuration file is checked out with the rest of the worktree,
+	// rather than next to the bare directory that holds the git config.
+	gitFile := `gitdir: /Users/conner/code/pulse-fork/.bare/worktrees/main`
+	gitConfigFile := `
+		[remote "origin"]
+			url = git@github.com:conner/pulse-fork.git
+			fetch = +refs/heads/*:refs/remotes/origin/*
+	`
+
+	fileSystemMock := readerMock{
+		Directories: []string{
+			"/Users/conner/code/pulse-fork/main/server",
+			"/Users/conner/code/pulse-fork/main",
+		},
+		Entries: map[string][]fs.DirEntry{
+			"/Users/conner/code/pulse-fork/main/server": {
+				newFileEntry("server.go", false),
+			},
+			"/Users/conner/code/pulse-fork/main": {
+				newFileEntry("server", true),
+				newFileEntry(".git", false),
+				newFileEntry(".pulse.yaml", false),
+			},
+		},
+		FileContents: map[string][]byte{
+			"/Users/conner/code/pulse-fork/main/.git":        []byte(gitFile),
+			"/Users/conner/code/pulse-fork/.bare/config":     []byte(gitConfigFile),
+			"/Users/conner/code/pulse-fork/main/.pulse.yaml": []byte("name: renamed\n"),
+		},
+	}
+
+	f := git.New()
+	f.Reader = &fileSystemMock
+
+	file, err := f.ParseFile("/Users/conner/code/pulse-fork/main/server/server.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Root != "/Users/conner/code/pulse-fork/main" {
+		t.Errorf("expected the root /Users/conner/code/pulse-fork/main, got %s", file.Root)
+	}
+	if file.Repository != "renamed" {
+		t.Errorf("expected the repository renamed, got %s", file.Repository)
+	}
+	if file.Path != "renamed/server/server.go" {
+		t.Errorf("expected the path renamed/server/server.go, got %s", file.Path)
+	}
+}
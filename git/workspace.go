@@ -0,0 +1,58 @@
+package git
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+
+	"github.com/creativecreature/pulse"
+)
+
+// ErrOutsideWorkspaces is returned if a file isn't within any of the workspaces.
+var ErrOutsideWorkspaces = errors.New("the path is not within any of the workspaces")
+
+// ParseWorkspaceFile parses a file that isn't under source control. The file
+// is attributed to the workspace with the most specific root that contains it.
+func (f FileParser) ParseWorkspaceFile(absolutePath string, workspaces []pulse.Workspace) (pulse.GitFile, error) {
+	if absolutePath == "" {
+		return pulse.GitFile{}, ErrEmptyPath
+	}
+
+	// It could be a temporary buffer or directory.
+	if !f.Reader.IsFile(absolutePath) {
+		return pulse.GitFile{}, ErrPathNotAFile
+	}
+
+	var workspace pulse.Workspace
+	var root string
+	for _, w := range workspaces {
+		r := w.Root()
+		if strings.HasPrefix(absolutePath, r+"/") && len(r) > len(root) {
+			workspace, root = w, r
+		}
+	}
+	if root == "" {
+		return pulse.GitFile{}, ErrOutsideWorkspaces
+	}
+
+	// Tries to get the filetype from either the file extension or name.
+	filename := filepath.Base(absolutePath)
+	ft, err := Filetype(filename)
+	if err != nil {
+		return pulse.GitFile{}, err
+	}
+
+	return pulse.GitFile{
+		Name:       filename,
+		Filetype:   ft,
+		Repository: workspace.Name,
+		Path:       workspace.Name + "/" + strings.TrimPrefix(absolutePath, root+"/"),
+		Root:       root,
+		Workspace:  true,
+		Project:    f.detectProject(root, absolutePath),
+	}, nil
+}
+
+func ParseWorkspaceFile(absolutePath string, workspaces []pulse.Workspace) (pulse.GitFile, error) {
+	return New().ParseWorkspaceFile(absolutePath, workspaces)
+}
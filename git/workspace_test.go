@@ -0,0 +1,74 @@
+package git_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/git"
+)
+
+func TestParseWorkspaceFile(t *testing.T) {
+	t.Parallel()
+
+	workspaces := []pulse.Workspace{
+		{Name: "notes", Path: "/Users/conner/notes"},
+		{Name: "journal", Path: "/Users/conner/notes/journal/"},
+	}
+
+	testCases := []struct {
+		name               string
+		path               string
+		expectedRepository string
+		expectedPath       string
+		expectedErr        error
+	}{
+		{
+			name:               "file in workspace",
+			path:               "/Users/conner/notes/ideas/todo.md",
+			expectedRepository: "notes",
+			expectedPath:       "notes/ideas/todo.md",
+		},
+		{
+			name:               "most specific workspace wins",
+			path:               "/Users/conner/notes/journal/2024.md",
+			expectedRepository: "journal",
+			expectedPath:       "journal/2024.md",
+		},
+		{
+			name:        "file outside of workspaces",
+			path:        "/Users/conner/notesbook/todo.md",
+			expectedErr: git.ErrOutsideWorkspaces,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			f := git.New()
+			f.Reader = &readerMock{}
+
+			file, err := f.ParseWorkspaceFile(tc.path, workspaces)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tc.expectedErr, err)
+			}
+			if tc.expectedErr != nil {
+				return
+			}
+			if file.Repository != tc.expectedRepository {
+				t.Errorf("expected repository %s, got %s", tc.expectedRepository, file.Repository)
+			}
+			if file.Path != tc.expectedPath {
+				t.Errorf("expected path %s, got %s", tc.expectedPath, file.Path)
+			}
+			if !file.Workspace {
+				t.Error("expected the file to be marked as a workspace file")
+			}
+			if file.Branch != "" {
+				t.Errorf("expected no branch, got %s", file.Branch)
+			}
+		})
+	}
+}
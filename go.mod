@@ -1,6 +1,6 @@
 module github.com/creativecreature/pulse
 
-go 1.21.3
+go 1.22
 
 require (
 	github.com/charmbracelet/lipgloss v0.10.0
@@ -0,0 +1,225 @@
+package pulse
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// The periods that a goal can be set for.
+const (
+	GoalDaily  = "daily"
+	GoalWeekly = "weekly"
+)
+
+// ErrAmbiguousGoal is returned if a goal is set for both a repository and a filetype.
+var ErrAmbiguousGoal = errors.New("a goal can't be set for both a repository and a filetype")
+
+// Goal is the amount of time that we want to spend coding each day or
+// week. It applies to all of the time, a single repository, or a filetype.
+type Goal struct {
+	Period     string
+	Target     time.Duration
+	Repository string
+	Filetype   string
+}
+
+// Validate returns an error if the progress towards the goal can't be measured.
+func (g Goal) Validate() error {
+	if g.Period != GoalDaily && g.Period != GoalWeekly {
+		return fmt.Errorf("invalid goal period %q", g.Period)
+	}
+	if g.Target <= 0 {
+		return fmt.Errorf("invalid target %s for the %s goal", g.Target, g.Name())
+	}
+	if g.Repository != "" && g.Filetype != "" {
+		return ErrAmbiguousGoal
+	}
+	return nil
+}
+
+// Name identifies the goal, e.g. daily or weekly:repository:pulse.
+func (g Goal) Name() string {
+	switch {
+	case g.Repository != "":
+		return g.Period + ":repository:" + g.Repository
+	case g.Filetype != "":
+		return g.Period + ":filetype:" + g.Filetype
+	}
+	return g.Period
+}
+
+// amount returns the time of the day that counts towards the goal.
+func (g Goal) amount(day Summary) int64 {
+	switch {
+	case g.Repository != "":
+		return day.Repositories[g.Repository]
+	case g.Filetype != "":
+		return day.Filetypes[g.Filetype]
+	}
+	return day.TotalTimeMs
+}
+
+// GoalProgress is the progress towards a goal on a given day. For weekly goals,
+// the progress is the time of the week up until, and including, that day. The
+// streak is the number of consecutive days, or weeks, that the goal was met.
+type GoalProgress struct {
+	Name       string `bson:"name"`
+	Period     string `bson:"period"`
+	TargetMs   int64  `bson:"target_ms"`
+	ProgressMs int64  `bson:"progress_ms"`
+	Met        bool   `bson:"met"`
+	Streak     int    `bson:"streak"`
+}
+
+// EvaluateGoals returns the progress towards each of the valid goals on the
+// given date. The days are the daily summaries, in any order and with gaps
+// for the days without any time. Only the days up until the date are used.
+//
+// The days are stepped through by their date strings, rather than by adding
+// 24 hours to a timestamp. A day that is 23 or 25 hours long due to daylight
+// saving time would otherwise skip or repeat a date, and break the streak.
+func EvaluateGoals(goals []Goal, date string, days []Summary) []GoalProgress {
+	if _, err := time.Parse(dateLayout, date); err != nil {
+		return nil
+	}
+
+	byDate := make(map[string]Summary, len(days))
+	var first string
+	for _, day := range days {
+		if day.DateString > date {
+			continue
+		}
+		if first == "" || day.DateString < first {
+			first = day.DateString
+		}
+		byDate[day.DateString] = day
+	}
+
+	progress := make([]GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		if goal.Validate() != nil {
+			continue
+		}
+
+		// Weekly goals are stepped through a week at a time, from the start of the week.
+		step, start := -1, date
+		if goal.Period == GoalWeekly {
+			step, start = -7, startOfWeek(date)
+		}
+		amount := func(from string) int64 {
+			if goal.Period == GoalDaily {
+				return goal.amount(byDate[from])
+			}
+			var total int64
+			for d := from; d <= date && d < addDays(from, 7); d = addDays(d, 1) {
+				total += goal.amount(byDate[d])
+			}
+			return total
+		}
+
+		p := GoalProgress{
+			Name:       goal.Name(),
+			Period:     goal.Period,
+			TargetMs:   goal.Target.Milliseconds(),
+			ProgressMs: amount(start),
+		}
+		p.Met = p.ProgressMs >= p.TargetMs
+
+		// A goal that hasn't been met yet doesn't break the streak
+		// until the day, or week, is over. It's counted from the
+		// previous one instead, which has to have been met.
+		from := start
+		if !p.Met {
+			from = addDays(start, step)
+		}
+		for ; first != "" && from >= startOfWeekOrDay(goal, first); from = addDays(from, step) {
+			if amount(from) < p.TargetMs {
+				break
+			}
+			p.Streak++
+		}
+		progress = append(progress, p)
+	}
+	return progress
+}
+
+// goalHistoryDays is the number of days before the date that are read to
+// evaluate the goals at first. The window is doubled while a streak reaches
+// its start, which means that we rarely have to read all of the history.
+const goalHistoryDays = 28
+
+// EvaluateGoalsWithHistory returns the progress towards the goals on the date
+// of today's summary. The days before it are read a window at a time. The read
+// function returns the daily summaries from the date, and up until today.
+func EvaluateGoalsWithHistory(goals []Goal, today Summary, read func(from string) ([]Summary, error)) ([]GoalProgress, error) {
+	previousLen := -1
+	for window := goalHistoryDays; ; window *= 2 {
+		from := addDays(today.DateString, -window)
+		history, err := read(from)
+		if err != nil {
+			return nil, err
+		}
+
+		days := append(append(make([]Summary, 0, len(history)+1), history...), today)
+		progress := EvaluateGoals(goals, today.DateString, days)
+		// If a wider window didn't add any days, there is nothing left to read.
+		if len(history) == previousLen || !isStreakCut(goals, today.DateString, from, progress) {
+			return progress, nil
+		}
+		previousLen = len(history)
+	}
+}
+
+// isStreakCut reports whether any of the streaks could continue before the
+// start of the window. That's the case if the period that ended the streak
+// isn't entirely within the window, since we haven't read all of its days.
+func isStreakCut(goals []Goal, date, windowStart string, progress []GoalProgress) bool {
+	i := 0
+	for _, goal := range goals {
+		if goal.Validate() != nil {
+			continue
+		}
+		p := progress[i]
+		i++
+
+		step, from := -1, date
+		if goal.Period == GoalWeekly {
+			step, from = -7, startOfWeek(date)
+		}
+		if !p.Met {
+			from = addDays(from, step)
+		}
+		if addDays(from, step*p.Streak) < windowStart {
+			return true
+		}
+	}
+	return false
+}
+
+// startOfWeekOrDay returns the first date of the goal's period that contains the date.
+func startOfWeekOrDay(goal Goal, date string) string {
+	if goal.Period == GoalWeekly {
+		return startOfWeek(date)
+	}
+	return date
+}
+
+// addDays adds a number of days to a date string.
+func addDays(date string, days int) string {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return date
+	}
+	return t.AddDate(0, 0, days).Format(dateLayout)
+}
+
+// startOfWeek returns the date of the monday of the week that contains the date.
+func startOfWeek(date string) string {
+	t, err := time.Parse(dateLayout, date)
+	if err != nil {
+		return date
+	}
+	offset := (int(t.Weekday()) + 6) % 7
+	return t.AddDate(0, 0, -offset).Format(dateLayout)
+}
@@ -0,0 +1,250 @@
+package pulse_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+// day creates a summary where all of the time was spent on go in the pulse repository.
+func day(date string, duration time.Duration) pulse.Summary {
+	summary := pulse.NewSummary(date)
+	summary.TotalTimeMs = duration.Milliseconds()
+	summary.Repositories["pulse"] = duration.Milliseconds()
+	summary.Filetypes["go"] = duration.Milliseconds()
+	return summary
+}
+
+func TestEvaluateGoals(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name             string
+		goal             pulse.Goal
+		date             string
+		days             []pulse.Summary
+		expectedProgress time.Duration
+		expectedMet      bool
+		expectedStreak   int
+	}{
+		{
+			name: "consecutive days",
+			goal: pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date: "2024-01-03",
+			days: []pulse.Summary{
+				day("2024-01-03", time.Hour),
+				day("2024-01-01", 2*time.Hour),
+				day("2024-01-02", time.Hour),
+			},
+			expectedProgress: time.Hour,
+			expectedMet:      true,
+			expectedStreak:   3,
+		},
+		{
+			name: "a day without any time breaks the streak",
+			goal: pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date: "2024-01-04",
+			days: []pulse.Summary{
+				day("2024-01-01", time.Hour),
+				day("2024-01-03", time.Hour),
+				day("2024-01-04", time.Hour),
+			},
+			expectedProgress: time.Hour,
+			expectedMet:      true,
+			expectedStreak:   2,
+		},
+		{
+			name: "the streak continues until the day is over",
+			goal: pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date: "2024-01-03",
+			days: []pulse.Summary{
+				day("2024-01-01", time.Hour),
+				day("2024-01-02", time.Hour),
+				day("2024-01-03", 30*time.Minute),
+			},
+			expectedProgress: 30 * time.Minute,
+			expectedMet:      false,
+			expectedStreak:   2,
+		},
+		{
+			name: "days after the date are ignored",
+			goal: pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date: "2024-01-01",
+			days: []pulse.Summary{
+				day("2024-01-01", time.Hour),
+				day("2024-01-02", time.Hour),
+			},
+			expectedProgress: time.Hour,
+			expectedMet:      true,
+			expectedStreak:   1,
+		},
+		{
+			name: "daylight saving time",
+			goal: pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date: "2024-04-01",
+			days: []pulse.Summary{
+				day("2024-03-30", time.Hour),
+				day("2024-03-31", time.Hour),
+				day("2024-04-01", time.Hour),
+			},
+			expectedProgress: time.Hour,
+			expectedMet:      true,
+			expectedStreak:   3,
+		},
+		{
+			name: "repository",
+			goal: pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour, Repository: "other"},
+			date: "2024-01-01",
+			days: []pulse.Summary{
+				day("2024-01-01", time.Hour),
+			},
+			expectedProgress: 0,
+			expectedMet:      false,
+			expectedStreak:   0,
+		},
+		{
+			name: "weekly",
+			goal: pulse.Goal{Period: pulse.GoalWeekly, Target: 2 * time.Hour, Filetype: "go"},
+			date: "2024-01-10",
+			days: []pulse.Summary{
+				// Monday and Wednesday of the first week.
+				day("2024-01-01", time.Hour),
+				day("2024-01-03", time.Hour),
+				// Tuesday and Wednesday of the second week, and the day after the date.
+				day("2024-01-09", time.Hour),
+				day("2024-01-10", 30*time.Minute),
+				day("2024-01-11", time.Hour),
+			},
+			expectedProgress: 90 * time.Minute,
+			expectedMet:      false,
+			expectedStreak:   1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			progress := pulse.EvaluateGoals([]pulse.Goal{tc.goal}, tc.date, tc.days)
+			if len(progress) != 1 {
+				t.Fatalf("expected the progress of 1 goal, got %d", len(progress))
+			}
+			p := progress[0]
+			if p.ProgressMs != tc.expectedProgress.Milliseconds() {
+				t.Errorf("expected the progress %d, got %d", tc.expectedProgress.Milliseconds(), p.ProgressMs)
+			}
+			if p.Met != tc.expectedMet {
+				t.Errorf("expected met to be %v, got %v", tc.expectedMet, p.Met)
+			}
+			if p.Streak != tc.expectedStreak {
+				t.Errorf("expected the streak %d, got %d", tc.expectedStreak, p.Streak)
+			}
+		})
+	}
+}
+
+func TestInvalidGoals(t *testing.T) {
+	t.Parallel()
+
+	err := pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour, Repository: "pulse", Filetype: "go"}.Validate()
+	if !errors.Is(err, pulse.ErrAmbiguousGoal) {
+		t.Errorf("expected an ambiguous goal error, got %v", err)
+	}
+
+	goals := []pulse.Goal{{Period: "monthly", Target: time.Hour}, {Period: pulse.GoalDaily}}
+	if progress := pulse.EvaluateGoals(goals, "2024-01-01", nil); len(progress) != 0 {
+		t.Errorf("expected the invalid goals to be skipped, got %+v", progress)
+	}
+}
+
+func TestEvaluateGoalsWithHistory(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name           string
+		goal           pulse.Goal
+		date           string
+		history        []string
+		expectedStreak int
+		expectedReads  int
+	}{
+		{
+			name:           "without any history",
+			goal:           pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date:           "2024-07-31",
+			expectedStreak: 1,
+			expectedReads:  1,
+		},
+		{
+			name:           "a streak within the first window",
+			goal:           pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date:           "2024-07-31",
+			history:        dates("2024-07-26", 1, 5),
+			expectedStreak: 6,
+			expectedReads:  1,
+		},
+		{
+			name:           "a daily streak beyond the first window",
+			goal:           pulse.Goal{Period: pulse.GoalDaily, Target: time.Hour},
+			date:           "2024-07-31",
+			history:        dates("2024-04-22", 1, 100),
+			expectedStreak: 101,
+			expectedReads:  3,
+		},
+		{
+			name:           "a weekly streak beyond the first window",
+			goal:           pulse.Goal{Period: pulse.GoalWeekly, Target: time.Hour},
+			date:           "2024-07-31",
+			history:        dates("2024-03-11", 7, 20),
+			expectedStreak: 21,
+			expectedReads:  4,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			history := make([]pulse.Summary, 0, len(tc.history))
+			for _, date := range tc.history {
+				history = append(history, day(date, time.Hour))
+			}
+
+			reads := 0
+			read := func(from string) ([]pulse.Summary, error) {
+				reads++
+				days := make([]pulse.Summary, 0)
+				for _, d := range history {
+					if d.DateString >= from {
+						days = append(days, d)
+					}
+				}
+				return days, nil
+			}
+
+			progress, err := pulse.EvaluateGoalsWithHistory([]pulse.Goal{tc.goal}, day(tc.date, time.Hour), read)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(progress) != 1 || progress[0].Streak != tc.expectedStreak {
+				t.Errorf("expected a streak of %d, got %+v", tc.expectedStreak, progress)
+			}
+			if reads != tc.expectedReads {
+				t.Errorf("expected %d reads, got %d", tc.expectedReads, reads)
+			}
+		})
+	}
+}
+
+// dates returns a number of date strings, a given number of days apart.
+func dates(first string, step, count int) []string {
+	start, _ := time.Parse("2006-01-02", first)
+	dates := make([]string, 0, count)
+	for i := range count {
+		dates = append(dates, start.AddDate(0, 0, i*step).Format("2006-01-02"))
+	}
+	return dates
+}
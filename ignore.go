@@ -0,0 +1,110 @@
+package pulse
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// IgnoreFilename is the name of the file that can be used to
+// ignore paths within a repository. It's placed at the root.
+const IgnoreFilename = ".pulseignore"
+
+// IgnoreRules determine which files shouldn't be tracked.
+type IgnoreRules struct {
+	repositories []string
+	paths        []string
+	filetypes    []string
+}
+
+// NewIgnoreRules creates ignore rules from glob patterns for the repository
+// names and paths, and a list of filetypes. Path patterns work much like the
+// ones in a .gitignore file: a pattern without a slash matches a file or
+// directory at any depth, a pattern with a slash matches from the root of
+// the repository, and a trailing slash only matches directories.
+func NewIgnoreRules(repositories, paths, filetypes []string) (IgnoreRules, error) {
+	for _, pattern := range append(append([]string{}, repositories...), paths...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return IgnoreRules{}, fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+	}
+	return IgnoreRules{repositories: repositories, paths: paths, filetypes: filetypes}, nil
+}
+
+// ParseIgnoreFile returns the path patterns of an ignore file.
+// Blank lines, and lines that start with a #, are skipped.
+func ParseIgnoreFile(content []byte) []string {
+	patterns := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if _, err := path.Match(line, ""); err != nil {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	return patterns
+}
+
+// With returns a copy of the rules that also ignores the given path patterns.
+func (r IgnoreRules) With(paths []string) IgnoreRules {
+	if len(paths) == 0 {
+		return r
+	}
+	r.paths = append(append([]string{}, r.paths...), paths...)
+	return r
+}
+
+// Ignores reports whether the file matches any of the rules.
+func (r IgnoreRules) Ignores(file GitFile) bool {
+	for _, pattern := range r.repositories {
+		if ok, _ := path.Match(pattern, file.Repository); ok {
+			return true
+		}
+	}
+
+	for _, filetype := range r.filetypes {
+		if strings.EqualFold(filetype, file.Filetype) {
+			return true
+		}
+	}
+
+	relativePath := strings.TrimPrefix(file.Path, file.Repository+"/")
+	for _, pattern := range r.paths {
+		if matchPath(pattern, relativePath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPath reports whether the path, relative to the root
+// of the repository, or any of its directories match the pattern.
+func matchPath(pattern, relativePath string) bool {
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	segments := strings.Split(relativePath, "/")
+
+	// The last segment is the file itself, which can't match a directory pattern.
+	if dirOnly {
+		segments = segments[:len(segments)-1]
+	}
+
+	anchored := strings.Contains(pattern, "/")
+	for i, segment := range segments {
+		candidate := segment
+		if anchored {
+			candidate = strings.Join(segments[:i+1], "/")
+		}
+		if ok, _ := path.Match(pattern, candidate); ok {
+			return true
+		}
+	}
+	return false
+}
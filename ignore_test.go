@@ -0,0 +1,74 @@
+package pulse_test
+
+import (
+	"testing"
+
+	"github.com/creativecreature/pulse"
+)
+
+func TestIgnoreRules(t *testing.T) {
+	t.Parallel()
+
+	rules, err := pulse.NewIgnoreRules(
+		[]string{"client-*"},
+		[]string{"vendor/", "*.pb.go", "docs/generated", "node_modules"},
+		[]string{"Markdown"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		file     pulse.GitFile
+		expected bool
+	}{
+		{"repository", pulse.GitFile{Repository: "client-acme", Path: "client-acme/main.go", Filetype: "go"}, true},
+		{"tracked", pulse.GitFile{Repository: "pulse", Path: "pulse/main.go", Filetype: "go"}, false},
+		{"filetype", pulse.GitFile{Repository: "pulse", Path: "pulse/README.md", Filetype: "markdown"}, true},
+		{"nested directory", pulse.GitFile{Repository: "pulse", Path: "pulse/cmd/vendor/x/y.go", Filetype: "go"}, true},
+		{"file named like a directory", pulse.GitFile{Repository: "pulse", Path: "pulse/cmd/vendor", Filetype: "go"}, false},
+		{"basename", pulse.GitFile{Repository: "pulse", Path: "pulse/api/api.pb.go", Filetype: "go"}, true},
+		{"anchored", pulse.GitFile{Repository: "pulse", Path: "pulse/docs/generated/index.go", Filetype: "go"}, true},
+		{"not anchored", pulse.GitFile{Repository: "pulse", Path: "pulse/api/docs/generated/index.go", Filetype: "go"}, false},
+		{"directory without slash", pulse.GitFile{Repository: "pulse", Path: "pulse/web/node_modules/a/b.js", Filetype: "javascript"}, true},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			if actual := rules.Ignores(tc.file); actual != tc.expected {
+				t.Errorf("expected Ignores(%s) to be %v, got %v", tc.file.Path, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestIgnoreRulesWithIgnoreFile(t *testing.T) {
+	t.Parallel()
+
+	rules, err := pulse.NewIgnoreRules(nil, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := pulse.GitFile{Repository: "pulse", Path: "pulse/scratch/notes.go", Filetype: "go"}
+	if rules.Ignores(file) {
+		t.Fatal("expected the file to be tracked without an ignore file")
+	}
+
+	patterns := pulse.ParseIgnoreFile([]byte("# Scratch files\n\nscratch/\n"))
+	if !rules.With(patterns).Ignores(file) {
+		t.Error("expected the patterns of the ignore file to be honored")
+	}
+}
+
+func TestIgnoreRulesRejectInvalidPatterns(t *testing.T) {
+	t.Parallel()
+
+	_, err := pulse.NewIgnoreRules([]string{"["}, nil, nil)
+	if err == nil {
+		t.Error("expected an invalid pattern to be rejected")
+	}
+}
@@ -3,7 +3,9 @@ package pulse
 import (
 	"context"
 	"os"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -19,12 +21,14 @@ type Record struct {
 // LogDB is a simple key-value store that persists data to a log file.
 type LogDB struct {
 	sync.RWMutex
-	dirPath          string
-	segmentSizeBytes int64
-	clock            clock.Clock
-	log              *log.Logger
-	head             *Segment
-	tail             *Segment
+	dirPath              string
+	segmentSizeBytes     int64
+	segmentationInterval atomic.Int64
+	intervalChanged      chan struct{}
+	clock                clock.Clock
+	log                  *log.Logger
+	head                 *Segment
+	tail                 *Segment
 }
 
 // NewDB creates a new log database.
@@ -46,6 +50,7 @@ func NewDB(dirPath string, segmentSizeKB int, c clock.Clock) *LogDB {
 	logDB.segmentSizeBytes = int64(segmentSizeKB) * 1024
 	logDB.log = log
 	logDB.clock = c
+	logDB.intervalChanged = make(chan struct{}, 1)
 
 	// If the directory is empty, we'll simply create the initial segment and return.
 	if len(segmentPaths) == 0 {
@@ -68,18 +73,43 @@ func NewDB(dirPath string, segmentSizeKB int, c clock.Clock) *LogDB {
 
 // RunSegmentations starts the database's compaction process.
 func (db *LogDB) RunSegmentations(ctx context.Context, segmentationInterval time.Duration) {
+	db.segmentationInterval.Store(int64(segmentationInterval))
 	c, cancel := db.clock.NewTicker(segmentationInterval)
-	defer cancel()
+	defer func() { cancel() }()
 	for {
 		select {
 		case <-c:
 			db.compact()
+		case <-db.intervalChanged:
+			cancel()
+			c, cancel = db.clock.NewTicker(time.Duration(db.segmentationInterval.Load()))
 		case <-ctx.Done():
 			return
 		}
 	}
 }
 
+// SetSegmentationInterval changes the interval of a running compaction process.
+func (db *LogDB) SetSegmentationInterval(segmentationInterval time.Duration) {
+	db.segmentationInterval.Store(int64(segmentationInterval))
+	select {
+	case db.intervalChanged <- struct{}{}:
+	default:
+	}
+}
+
+// SetSegmentSize changes the size at which a new segment is appended.
+func (db *LogDB) SetSegmentSize(segmentSizeKB int) {
+	db.Lock()
+	defer db.Unlock()
+	db.segmentSizeBytes = int64(segmentSizeKB) * 1024
+}
+
+// SetLogLevel changes the level of the database's logger.
+func (db *LogDB) SetLogLevel(level log.Level) {
+	db.log.SetLevel(level)
+}
+
 // appendSegment creates a new segment and appends it to the
 // head of the linked list. should be called with a lock.
 func (db *LogDB) appendSegment() {
@@ -173,7 +203,9 @@ func (db *LogDB) compact() {
 	}
 
 	for key, value := range valuesToWrite {
-		db.mustSet(key, value)
+		if err := db.set(key, value); err != nil {
+			db.log.Error("Failed to write a compacted value", "key", key, "err", err)
+		}
 	}
 	db.log.Info("Finished compacting segments")
 }
@@ -222,6 +254,32 @@ func (db *LogDB) GetAllUnique() map[string][]byte {
 	return values
 }
 
+// GetByPrefix retrieves the most recent value of every key that has the given prefix.
+func (db *LogDB) GetByPrefix(prefix string) map[string][]byte {
+	db.Lock()
+	defer db.Unlock()
+
+	values := make(map[string][]byte)
+	current := db.head
+	for {
+		for key := range current.hashIndex {
+			if _, ok := values[key]; ok || !strings.HasPrefix(key, prefix) {
+				continue
+			}
+			value, _ := current.get(key)
+			values[key] = value
+		}
+
+		// Update current and break if we've reached the tail.
+		if current.next == db.head || current.next == nil {
+			break
+		}
+
+		current = current.next
+	}
+	return values
+}
+
 // Set writes a key-value pair to the log file.
 func (db *LogDB) Set(key string, value []byte) error {
 	db.Lock()
@@ -249,7 +307,8 @@ func (db *LogDB) set(key string, value []byte) error {
 	return nil
 }
 
-// MustSet writes a key-value pair to the log file and panics on error.
+// MustSet writes a key-value pair to the log file and panics on
+// error. It's meant for callers that have no way of recovering.
 func (db *LogDB) MustSet(key string, value []byte) {
 	err := db.Set(key, value)
 	if err != nil {
@@ -258,15 +317,6 @@ func (db *LogDB) MustSet(key string, value []byte) {
 	}
 }
 
-// mustSet writes a key-value pair without a lock and panics on error.
-func (db *LogDB) mustSet(key string, value []byte) {
-	err := db.set(key, value)
-	if err != nil {
-		db.log.Error("%v", err)
-		panic(err)
-	}
-}
-
 // Aggregate gathers all the unique key-value pairs in the database,
 // and then removes all the segments and resets the state.
 func (db *LogDB) Aggregate() map[string][]byte {
@@ -2,6 +2,8 @@ package mongo
 
 import (
 	"context"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -24,6 +26,10 @@ type Client struct {
 	*mongo.Client
 	database string
 	log      *log.Logger
+
+	mu       sync.Mutex
+	goals    []pulse.Goal
+	calendar pulse.Calendar
 }
 
 func New(uri, database string) *Client {
@@ -42,37 +48,69 @@ func New(uri, database string) *Client {
 	}
 }
 
-func createDateFilter(minDate, maxDate int64) primitive.D {
+// createDateFilter matches the daily sessions of the days of the calendar
+// that the epochs fall on. The sessions are matched by their date strings rather than their
+// epochs, which depend on the timezone and start of the day that were
+// configured when they were written.
+func createDateFilter(cal pulse.Calendar, minDate, maxDate int64) primitive.D {
+	minDateString := cal.DateString(time.UnixMilli(minDate))
+	maxDateString := cal.DateString(time.UnixMilli(maxDate))
 	return bson.D{
 		{
 			Key: "$and",
 			Value: bson.A{
-				bson.D{{Key: "date", Value: bson.D{{Key: "$gte", Value: minDate}}}},
-				bson.D{{Key: "date", Value: bson.D{{Key: "$lte", Value: maxDate}}}},
+				bson.D{{Key: "date_string", Value: bson.D{{Key: "$gte", Value: minDateString}}}},
+				bson.D{{Key: "date_string", Value: bson.D{{Key: "$lte", Value: maxDateString}}}},
 			},
 		},
 	}
 }
 
-func (c *Client) getByDateRange(ctx context.Context, minDate, maxDate int64) (pulse.CodingSessions, error) {
-	filter := createDateFilter(minDate, maxDate)
+// normalizeDates sets the epochs of the sessions to the start of their days
+// in the calendar. Otherwise, a session that
+// was written before the start of the day was changed would be merged into
+// the wrong day, week, month, or year.
+func normalizeDates(cal pulse.Calendar, sessions pulse.CodingSessions) {
+	for i := range sessions {
+		if start, err := cal.ParseDate(sessions[i].DateString); err == nil {
+			sessions[i].EpochDateMs = start.UnixMilli()
+		}
+	}
+}
+
+func (c *Client) getByDateRange(ctx context.Context, cal pulse.Calendar, minDate, maxDate int64) (pulse.CodingSessions, error) {
+	filter := createDateFilter(cal, minDate, maxDate)
 	dateSortOpts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
 	cursor, err := c.Database(c.database).Collection(collectionDaily).Find(ctx, filter, dateSortOpts)
 	if err != nil {
 		return pulse.CodingSessions{}, err
 	}
 
-	results := make([]pulse.CodingSession, 0)
+	results := make(pulse.CodingSessions, 0)
 	err = cursor.All(ctx, &results)
 	if err != nil {
 		return pulse.CodingSessions{}, err
 	}
 
+	normalizeDates(cal, results)
 	return results, nil
 }
 
-func (c *Client) deleteByDateRange(ctx context.Context, minDate, maxDate int64) error {
-	filter := createDateFilter(minDate, maxDate)
+// alreadyWritten returns true if one of the writes of the session has been
+// merged into the stored sessions.
+func alreadyWritten(stored pulse.CodingSessions, session pulse.CodingSession) bool {
+	for _, s := range stored {
+		for _, id := range session.WriteIDs {
+			if slices.Contains(s.WriteIDs, id) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (c *Client) deleteByDateRange(ctx context.Context, cal pulse.Calendar, minDate, maxDate int64) error {
+	filter := createDateFilter(cal, minDate, maxDate)
 	_, err := c.Database(c.database).
 		Collection(collectionDaily).
 		DeleteMany(ctx, filter)
@@ -89,28 +127,34 @@ func (c *Client) insertAll(ctx context.Context, collection string, sessions []pu
 	return err
 }
 
-func (c *Client) readAll(ctx context.Context) (pulse.CodingSessions, error) {
+func (c *Client) readAll(ctx context.Context, cal pulse.Calendar) (pulse.CodingSessions, error) {
 	sortOpts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
 	cursor, err := c.Database(c.database).Collection(collectionDaily).Find(ctx, bson.M{}, sortOpts)
 	if err != nil {
 		return pulse.CodingSessions{}, err
 	}
 
-	results := make([]pulse.CodingSession, 0)
+	results := make(pulse.CodingSessions, 0)
 	err = cursor.All(ctx, &results)
 	if err != nil {
 		return pulse.CodingSessions{}, err
 	}
 
+	normalizeDates(cal, results)
 	return results, nil
 }
 
-func (c *Client) aggregate(ctx context.Context) error {
-	dailySessions, err := c.readAll(ctx)
+func (c *Client) aggregate(ctx context.Context, cal pulse.Calendar) error {
+	dailySessions, err := c.readAll(ctx, cal)
 	if err != nil {
 		return err
 	}
 
+	// The IDs of the writes are only needed to deduplicate the daily sessions.
+	for i := range dailySessions {
+		dailySessions[i].WriteIDs = nil
+	}
+
 	// Aggregate by week.
 	c.log.Info("Dropping the previous aggregation for this week.")
 	err = c.Database(c.database).Collection(collectionWeekly).Drop(ctx)
@@ -118,7 +162,7 @@ func (c *Client) aggregate(ctx context.Context) error {
 		return err
 	}
 	c.log.Info("Generating a new weekly aggregation.")
-	err = c.insertAll(ctx, collectionWeekly, dailySessions.MergeByWeek())
+	err = c.insertAll(ctx, collectionWeekly, dailySessions.MergeByWeek(cal))
 	if err != nil {
 		return err
 	}
@@ -130,7 +174,7 @@ func (c *Client) aggregate(ctx context.Context) error {
 		return err
 	}
 	c.log.Info("Generating a new monthly aggregation.")
-	err = c.insertAll(ctx, collectionMonthly, dailySessions.MergeByMonth())
+	err = c.insertAll(ctx, collectionMonthly, dailySessions.MergeByMonth(cal))
 	if err != nil {
 		return err
 	}
@@ -142,7 +186,83 @@ func (c *Client) aggregate(ctx context.Context) error {
 		return err
 	}
 	c.log.Info("Generating a new yearly aggregation.")
-	return c.insertAll(ctx, collectionYearly, dailySessions.MergeByYear())
+	return c.insertAll(ctx, collectionYearly, dailySessions.MergeByYear(cal))
+}
+
+// SetGoals sets the goals that are evaluated each time a daily session is written.
+func (c *Client) SetGoals(goals []pulse.Goal) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.goals = goals
+}
+
+// SetCalendar sets the calendar that the sessions are bucketed into days,
+// weeks, months, and years with.
+func (c *Client) SetCalendar(cal pulse.Calendar) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calendar = cal
+}
+
+// currentCalendar returns the calendar that the sessions are bucketed with.
+func (c *Client) currentCalendar() pulse.Calendar {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.calendar
+}
+
+// evaluateGoals evaluates the goals on the day of the session, which has
+// yet to be written, using the daily sessions that have been stored.
+func (c *Client) evaluateGoals(ctx context.Context, cal pulse.Calendar, session pulse.CodingSession) ([]pulse.GoalProgress, error) {
+	c.mu.Lock()
+	goals := c.goals
+	c.mu.Unlock()
+	if len(goals) == 0 {
+		return nil, nil
+	}
+
+	today := pulse.NewSummary(session.DateString)
+	today.AddSession(session)
+	return pulse.EvaluateGoalsWithHistory(goals, today, func(from string) ([]pulse.Summary, error) {
+		minDate, err := cal.ParseDate(from)
+		if err != nil {
+			return nil, err
+		}
+		dailySessions, err := c.getByDateRange(ctx, cal, minDate.UnixMilli(), session.EpochDateMs-1)
+		if err != nil {
+			return nil, err
+		}
+		days := make([]pulse.Summary, 0, len(dailySessions))
+		for _, s := range dailySessions.MergeByDay(cal) {
+			day := pulse.NewSummary(s.DateString)
+			day.AddSession(s)
+			days = append(days, day)
+		}
+		return days, nil
+	})
+}
+
+// ReadRange returns the daily coding sessions between the given epochs.
+func (c *Client) ReadRange(ctx context.Context, minDate, maxDate int64) (pulse.CodingSessions, error) {
+	cal := c.currentCalendar()
+	sessions, err := c.getByDateRange(ctx, cal, minDate, maxDate)
+	if err != nil {
+		return pulse.CodingSessions{}, err
+	}
+	return sessions.MergeByDay(cal), nil
+}
+
+// Read returns the coding session of the day that contains the given epoch. The
+// day is matched by its date string, which includes the sessions that were stored
+// with another timezone or start of the day, e.g. before either was configured.
+func (c *Client) Read(ctx context.Context, epochDateMs int64) (pulse.CodingSession, error) {
+	cal := c.currentCalendar()
+	minDate, maxDate := cal.DayRange(epochDateMs)
+	sessions, err := c.getByDateRange(ctx, cal, minDate, maxDate)
+	if err != nil || len(sessions) == 0 {
+		return pulse.CodingSession{}, err
+	}
+	return sessions.MergeByDay(cal)[0], nil
 }
 
 // Write writes daily coding sessions to a mongodb collection.
@@ -150,18 +270,30 @@ func (c *Client) Write(ctx context.Context, session pulse.CodingSession) error {
 	// We might aggregate sessions from the temp storage several times a
 	// day. Therefore, we have to fetch any previous sessions for the same
 	// timeframe. If we have any, we'll merge them with the new ones.
-	minDate, maxDate := session.EpochDateMs, session.EpochDateMs
-	previousSessionsForRange, err := c.getByDateRange(ctx, minDate, maxDate)
+	cal := c.currentCalendar()
+	minDate, maxDate := cal.DayRange(session.EpochDateMs)
+	previousSessionsForRange, err := c.getByDateRange(ctx, cal, minDate, maxDate)
 	if err != nil {
 		return err
 	}
 
+	// A write that timed out could still have been stored. It's then
+	// retried from the outbox, and mustn't be counted a second time.
+	if alreadyWritten(previousSessionsForRange, session) {
+		c.log.Info("Skipping a session that has already been written.", "write_ids", session.WriteIDs)
+		return nil
+	}
+
 	// If there were no previous sessions for this range of dates, we'll simply insert them.
 	if len(previousSessionsForRange) == 0 {
 		c.log.Info("Inserting as is because no previous session have been aggregated for this day.",
 			"min_date", minDate,
 			"max_date", maxDate,
 		)
+		session.Goals, err = c.evaluateGoals(ctx, cal, session)
+		if err != nil {
+			return err
+		}
 		_, insertErr := c.Database(c.database).Collection(collectionDaily).InsertOne(ctx, session)
 		return insertErr
 	}
@@ -172,11 +304,19 @@ func (c *Client) Write(ctx context.Context, session pulse.CodingSession) error {
 	combinedSessions := make(pulse.CodingSessions, 0, len(previousSessionsForRange)+1)
 	combinedSessions = append(combinedSessions, previousSessionsForRange...)
 	combinedSessions = append(combinedSessions, session)
-	mergedSessions := combinedSessions.MergeByDay()
+	mergedSessions := combinedSessions.MergeByDay(cal)
+
+	// The progress towards the goals is evaluated again with the merged time.
+	for i := range mergedSessions {
+		mergedSessions[i].Goals, err = c.evaluateGoals(ctx, cal, mergedSessions[i])
+		if err != nil {
+			return err
+		}
+	}
 
 	// Delete the previously stored sessions for this range
 	c.log.Info("Deleting the previously aggregated session for this day.")
-	err = c.deleteByDateRange(ctx, minDate, maxDate)
+	err = c.deleteByDateRange(ctx, cal, minDate, maxDate)
 	if err != nil {
 		return err
 	}
@@ -190,5 +330,5 @@ func (c *Client) Write(ctx context.Context, session pulse.CodingSession) error {
 
 	// Lastly, we'll update the aggregated collections to be
 	// able to display the data per week, month, and year.
-	return c.aggregate(ctx)
+	return c.aggregate(ctx, cal)
 }
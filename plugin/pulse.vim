@@ -15,7 +15,11 @@ call remote#host#RegisterPlugin('pulse-client', '0', [
 			\ {'type': 'function', 'name': 'OnFocusGained', 'sync': 1, 'opts': {}},
 			\ {'type': 'function', 'name': 'OpenFile', 'sync': 1, 'opts': {}},
 			\ {'type': 'function', 'name': 'SendHeartbeat', 'sync': 1, 'opts': {}},
+			\ {'type': 'function', 'name': 'TextChanged', 'sync': 0, 'opts': {}},
+			\ {'type': 'function', 'name': 'CursorMoved', 'sync': 0, 'opts': {}},
+			\ {'type': 'function', 'name': 'BufferWritten', 'sync': 1, 'opts': {}},
 			\ {'type': 'function', 'name': 'EndSession', 'sync': 1, 'opts': {}},
+			\ {'type': 'function', 'name': 'PulseToday', 'sync': 1, 'opts': {}},
 			\ ])
 
 
@@ -32,7 +36,13 @@ autocmd BufEnter * :call call("OpenFile", [g:pulse_session_id, expand('%:p')])
 
 " We are sending a heartbeart each time we write a buffer.
 " This lets the server know that our session is still active.
-autocmd BufWrite * :call call("SendHeartbeat", [g:pulse_session_id, expand('%:p')])
+autocmd BufWrite * :call call("BufferWritten", [g:pulse_session_id, expand('%:p')])
+
+" Text changes and cursor moves are used to tell editing and reading apart.
+" They fire often, which is why they are sent without waiting for a reply,
+" and the client only passes on one of each every few seconds.
+autocmd TextChanged,TextChangedI * :call call("TextChanged", [g:pulse_session_id, expand('%:p')])
+autocmd CursorMoved,CursorMovedI * :call call("CursorMoved", [g:pulse_session_id, expand('%:p')])
 
 " When we exit VIM we inform the server that our coding session has ended.
 autocmd VimLeave * :call call("EndSession", [g:pulse_session_id, expand('%:p')])
@@ -0,0 +1,106 @@
+package pulse
+
+import (
+	"cmp"
+	"fmt"
+	"path"
+)
+
+// Project represents a sub-project of a repository, such as a service in
+// a monorepo. Its name is the path of its directory within the repository.
+type Project struct {
+	Name         string `bson:"name"`
+	Files        Files  `bson:"files"`
+	DurationMs   int64  `bson:"duration_ms"`
+	EditingMs    int64  `bson:"editing_ms"`
+	ReadingMs    int64  `bson:"reading_ms"`
+	Writes       int    `bson:"writes"`
+	LinesAdded   int    `bson:"lines_added"`
+	LinesRemoved int    `bson:"lines_removed"`
+}
+
+// merge takes two projects, merges them, and returns the result.
+func (a Project) merge(b Project) Project {
+	return Project{
+		Name:         cmp.Or(a.Name, b.Name),
+		Files:        a.Files.merge(b.Files),
+		DurationMs:   a.DurationMs + b.DurationMs,
+		EditingMs:    a.EditingMs + b.EditingMs,
+		ReadingMs:    a.ReadingMs + b.ReadingMs,
+		Writes:       a.Writes + b.Writes,
+		LinesAdded:   a.LinesAdded + b.LinesAdded,
+		LinesRemoved: a.LinesRemoved + b.LinesRemoved,
+	}
+}
+
+// Projects represents a slice of projects.
+type Projects []Project
+
+// projectsByName takes a slice of projects and returns a map
+// where the project name is the key and the project the value.
+func projectsByName(projects Projects) map[string]Project {
+	nameProjectMap := make(map[string]Project)
+	for _, project := range projects {
+		nameProjectMap[project.Name] = project
+	}
+	return nameProjectMap
+}
+
+// merge takes two slices of projects, merges them, and returns the result.
+func (a Projects) merge(b Projects) Projects {
+	aNames, bNames := projectsByName(a), projectsByName(b)
+	allNames := make(map[string]bool)
+	for name := range aNames {
+		allNames[name] = true
+	}
+	for name := range bNames {
+		allNames[name] = true
+	}
+
+	mergedProjects := make(Projects, 0, len(allNames))
+	for name := range allNames {
+		mergedProjects = append(mergedProjects, aNames[name].merge(bNames[name]))
+	}
+	return mergedProjects
+}
+
+// ProjectRules determine where the sub-projects of a repository begin. A
+// directory is a project if it contains a marker file, e.g. go.mod, or if
+// its path within the repository matches one of the path patterns.
+type ProjectRules struct {
+	markers []string
+	paths   []string
+}
+
+// NewProjectRules compiles the rules. Both the markers and paths are glob patterns.
+func NewProjectRules(markers, paths []string) (ProjectRules, error) {
+	for _, pattern := range append(append([]string{}, markers...), paths...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return ProjectRules{}, fmt.Errorf("invalid project pattern %q: %w", pattern, err)
+		}
+	}
+	return ProjectRules{markers: markers, paths: paths}, nil
+}
+
+// Enabled reports whether there are any rules to detect projects with.
+func (r ProjectRules) Enabled() bool {
+	return len(r.markers) > 0 || len(r.paths) > 0
+}
+
+// IsProject reports whether the directory, at the given path within the
+// repository, is a project. The filenames are the entries of the directory.
+func (r ProjectRules) IsProject(relativeDir string, filenames []string) bool {
+	for _, pattern := range r.paths {
+		if ok, _ := path.Match(pattern, relativeDir); ok {
+			return true
+		}
+	}
+	for _, pattern := range r.markers {
+		for _, filename := range filenames {
+			if ok, _ := path.Match(pattern, filename); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
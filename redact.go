@@ -0,0 +1,278 @@
+package pulse
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+)
+
+// The ways in which the paths of a repository can be redacted.
+const (
+	// RedactKeep keeps the paths as they are.
+	RedactKeep = "keep"
+	// RedactHash replaces the names and paths with a keyed hash.
+	RedactHash = "hash"
+	// RedactCollapse collapses the paths to the directories at a given depth.
+	RedactCollapse = "collapse"
+	// RedactFiletype only keeps the filetypes.
+	RedactFiletype = "filetype"
+)
+
+// hashLength is the number of hexadecimal characters that we keep of a hash.
+const hashLength = 16
+
+// ErrMissingSecret is returned if paths are to be hashed without a secret.
+var ErrMissingSecret = errors.New("hashing paths requires a secret")
+
+// RedactionRule determines how the paths of the repositories
+// with names that match the glob pattern are redacted.
+type RedactionRule struct {
+	Repository string `json:"repository"`
+	Mode       string `json:"mode"`
+	Depth      int    `json:"depth,omitempty"`
+}
+
+// Redactor removes the paths from a coding session before it leaves the machine.
+type Redactor struct {
+	secret []byte
+	rules  []RedactionRule
+}
+
+// NewRedactor creates a redactor. The first rule with a pattern that matches the
+// name of a repository is applied to it. Repositories without a rule are kept.
+func NewRedactor(secret string, rules []RedactionRule) (Redactor, error) {
+	for _, rule := range rules {
+		if err := validateRule(rule, secret); err != nil {
+			return Redactor{}, err
+		}
+	}
+	return Redactor{secret: []byte(secret), rules: rules}, nil
+}
+
+// validateRule returns an error if the rule can't be applied with the secret.
+func validateRule(rule RedactionRule, secret string) error {
+	if _, err := path.Match(rule.Repository, ""); err != nil {
+		return fmt.Errorf("invalid repository pattern %q: %w", rule.Repository, err)
+	}
+	switch rule.Mode {
+	case RedactKeep, RedactFiletype:
+	case RedactHash:
+		if secret == "" {
+			return ErrMissingSecret
+		}
+	case RedactCollapse:
+		if rule.Depth < 0 {
+			return fmt.Errorf("invalid depth %d for repository %q", rule.Depth, rule.Repository)
+		}
+	default:
+		return fmt.Errorf("invalid redaction mode %q", rule.Mode)
+	}
+	return nil
+}
+
+// strictness ranks the modes from the least to the most strict. Hashing
+// is stricter than collapsing, since it doesn't reveal any names.
+var strictness = map[string]int{
+	RedactKeep:     0,
+	RedactCollapse: 1,
+	RedactHash:     2,
+	RedactFiletype: 3,
+}
+
+// isAtLeastAsStrict reports whether the rule redacts at least as much as the
+// other. Collapsing to fewer directories is stricter than collapsing to more.
+func isAtLeastAsStrict(rule, other RedactionRule) bool {
+	if rule.Mode == RedactCollapse && other.Mode == RedactCollapse {
+		return rule.Depth <= other.Depth
+	}
+	return strictness[rule.Mode] >= strictness[other.Mode]
+}
+
+// rule returns the rule that applies to the repository. The rule from the
+// repository's own configuration file is only applied if it's at least as
+// strict as the configured rules, since anyone who is able to commit to the
+// repository is able to change it. If that rule is invalid, we'll only keep
+// the filetypes to be safe.
+func (r Redactor) rule(repo Repository) RedactionRule {
+	configured := RedactionRule{Mode: RedactKeep}
+	for _, rule := range r.rules {
+		if ok, _ := path.Match(rule.Repository, repo.Name); ok {
+			configured = rule
+			break
+		}
+	}
+
+	if repo.Redaction == nil {
+		return configured
+	}
+	if validateRule(*repo.Redaction, string(r.secret)) != nil {
+		return RedactionRule{Repository: repo.Name, Mode: RedactFiletype}
+	}
+	if isAtLeastAsStrict(*repo.Redaction, configured) {
+		return *repo.Redaction
+	}
+	return configured
+}
+
+// hash returns a keyed hash of the value.
+func (r Redactor) hash(value string) string {
+	mac := hmac.New(sha256.New, r.secret)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))[:hashLength]
+}
+
+// redactPath redacts a path, relative to the root of the repository. An
+// empty string is returned if nothing but the filetype is to be kept.
+func (r Redactor) redactPath(rule RedactionRule, relativePath string) string {
+	switch rule.Mode {
+	case RedactHash:
+		return r.hash(relativePath)
+	case RedactCollapse:
+		dirs := strings.Split(path.Dir(relativePath), "/")
+		if dirs[0] == "." {
+			dirs = nil
+		}
+		return path.Join(dirs[:min(rule.Depth, len(dirs))]...)
+	case RedactFiletype:
+		return ""
+	}
+	return relativePath
+}
+
+// redactFile redacts the name and path of a file. Files that
+// can no longer be told apart are grouped by their filetype.
+func (r Redactor) redactFile(rule RedactionRule, repository string, file File) File {
+	relativePath := strings.TrimPrefix(file.Path, repository+"/")
+	switch rule.Mode {
+	case RedactHash:
+		file.Name = r.hash(file.Name)
+		file.Path = path.Join(repository, r.redactPath(rule, relativePath))
+	case RedactCollapse, RedactFiletype:
+		file.Name = "*." + file.Filetype
+		file.Path = path.Join(repository, r.redactPath(rule, relativePath), file.Name)
+	}
+	return file
+}
+
+// redactProjectName redacts the name of a project, which is a path as well.
+// An empty string is returned if nothing is left of it after the redaction.
+func (r Redactor) redactProjectName(rule RedactionRule, name string) string {
+	switch rule.Mode {
+	case RedactHash:
+		return r.hash(name)
+	case RedactCollapse:
+		dirs := strings.Split(name, "/")
+		return path.Join(dirs[:min(rule.Depth, len(dirs))]...)
+	case RedactFiletype:
+		return ""
+	}
+	return name
+}
+
+// redactBranchName redacts the name of a branch, which tends to describe
+// the work that's being done. An empty string is returned if the name is
+// to be dropped, since there are no paths to collapse it to.
+func (r Redactor) redactBranchName(rule RedactionRule, name string) string {
+	switch rule.Mode {
+	case RedactHash:
+		return r.hash(name)
+	case RedactCollapse, RedactFiletype:
+		return ""
+	}
+	return name
+}
+
+// redactCommit redacts the branch and the paths of the committed files.
+func (r Redactor) redactCommit(rule RedactionRule, commit Commit) Commit {
+	if commit.Branch != "" {
+		commit.Branch = r.redactBranchName(rule, commit.Branch)
+	}
+	files := make([]string, 0, len(commit.Files))
+	for _, file := range commit.Files {
+		relativePath := strings.TrimPrefix(file, commit.Repository+"/")
+		redacted := r.redactPath(rule, relativePath)
+		if rule.Mode == RedactFiletype || slices.Contains(files, path.Join(commit.Repository, redacted)) {
+			continue
+		}
+		files = append(files, path.Join(commit.Repository, redacted))
+	}
+	commit.Files = files
+	return commit
+}
+
+// redactTicket redacts the ID of a ticket, which is parsed from the names of
+// the branches, by the strictest rule of the repositories that it was worked
+// on in. Tickets from before the repositories were recorded are kept. An
+// empty ID is returned if the ticket is to be dropped.
+func (r Redactor) redactTicket(rules map[string]RedactionRule, ticket Ticket) Ticket {
+	strictest := RedactionRule{Mode: RedactKeep}
+	for _, repository := range ticket.Repositories {
+		if rule, ok := rules[repository]; ok && isAtLeastAsStrict(rule, strictest) {
+			strictest = rule
+		}
+	}
+	ticket.ID = r.redactBranchName(strictest, ticket.ID)
+	return ticket
+}
+
+// Redact returns a copy of the session where the names and paths of the files,
+// and the names of the branches and tickets, have been redacted according to the rules.
+func (r Redactor) Redact(session CodingSession) CodingSession {
+	rules := make(map[string]RedactionRule, len(session.Repositories))
+	repositories := make(Repositories, 0, len(session.Repositories))
+	for _, repo := range session.Repositories {
+		rule := r.rule(repo)
+		rules[repo.Name] = rule
+		repo.Redaction = nil
+		if rule.Mode == RedactKeep {
+			repositories = append(repositories, repo)
+			continue
+		}
+
+		files := make(Files, 0, len(repo.Files))
+		for _, file := range repo.Files {
+			files = files.merge(Files{r.redactFile(rule, repo.Name, file)})
+		}
+		// The files of a project that nothing is left of are moved to the repository.
+		var projects Projects
+		for _, project := range repo.Projects {
+			projectFiles := make(Files, 0, len(project.Files))
+			for _, file := range project.Files {
+				projectFiles = projectFiles.merge(Files{r.redactFile(rule, repo.Name, file)})
+			}
+			if project.Name = r.redactProjectName(rule, project.Name); project.Name == "" {
+				files = files.merge(projectFiles)
+				continue
+			}
+			project.Files = projectFiles
+			projects = projects.merge(Projects{project})
+		}
+		branches := make(Branches, 0, len(repo.Branches))
+		for _, branch := range repo.Branches {
+			if branch.Name = r.redactBranchName(rule, branch.Name); branch.Name != "" {
+				branches = branches.merge(Branches{branch})
+			}
+		}
+		commits := make(Commits, 0, len(repo.Commits))
+		for _, commit := range repo.Commits {
+			commits = append(commits, r.redactCommit(rule, commit))
+		}
+
+		repo.Files, repo.Projects, repo.Branches, repo.Commits = files, projects, branches, commits
+		repositories = append(repositories, repo)
+	}
+
+	tickets := make(Tickets, 0, len(session.Tickets))
+	for _, ticket := range session.Tickets {
+		if ticket = r.redactTicket(rules, ticket); ticket.ID != "" {
+			tickets = tickets.merge(Tickets{ticket})
+		}
+	}
+	session.Repositories, session.Tickets = repositories, tickets
+	return session
+}
@@ -0,0 +1,333 @@
+package pulse_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+// redactionSession creates a session with three files in the same repository,
+// on a branch that references a ticket, and a commit that touched two of them.
+func redactionSession(repository string) pulse.CodingSession {
+	buffers := pulse.Buffers{}
+	for _, path := range []string{"internal/billing/invoice.go", "internal/billing/tax.go", "README.md"} {
+		filetype := "go"
+		if strings.HasSuffix(path, ".md") {
+			filetype = "markdown"
+		}
+		buffers = append(buffers, pulse.Buffer{
+			Filename:   path[strings.LastIndex(path, "/")+1:],
+			Filepath:   repository + "/" + path,
+			Filetype:   filetype,
+			Repository: repository,
+			Branch:     "PULSE-12-invoices",
+			Tickets:    []string{"PULSE-12"},
+			Duration:   time.Minute,
+		})
+	}
+	commits := pulse.Commits{{
+		SHA:        "abc",
+		Repository: repository,
+		Branch:     "PULSE-12-invoices",
+		Files:      []string{repository + "/internal/billing/invoice.go", repository + "/internal/billing/tax.go"},
+	}}
+	return pulse.NewCodingSessions(pulse.Calendar{}, buffers, commits, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))[0]
+}
+
+// paths returns the sorted paths of the files in the sessions first repository.
+func paths(session pulse.CodingSession) []string {
+	paths := make([]string, 0)
+	for _, file := range session.Repositories[0].Files {
+		paths = append(paths, file.Path)
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+func TestRedactor(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{
+		{Repository: "hashed", Mode: pulse.RedactHash},
+		{Repository: "collapsed", Mode: pulse.RedactCollapse, Depth: 1},
+		{Repository: "client-*", Mode: pulse.RedactFiletype},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		repository      string
+		expectedPaths   []string
+		expectedCommits []string
+	}{
+		{
+			repository:      "kept",
+			expectedPaths:   []string{"kept/README.md", "kept/internal/billing/invoice.go", "kept/internal/billing/tax.go"},
+			expectedCommits: []string{"kept/internal/billing/invoice.go", "kept/internal/billing/tax.go"},
+		},
+		{
+			repository:      "collapsed",
+			expectedPaths:   []string{"collapsed/*.markdown", "collapsed/internal/*.go"},
+			expectedCommits: []string{"collapsed/internal"},
+		},
+		{
+			repository:      "client-acme",
+			expectedPaths:   []string{"client-acme/*.go", "client-acme/*.markdown"},
+			expectedCommits: []string{},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.repository, func(t *testing.T) {
+			t.Parallel()
+			session := redactionSession(tc.repository)
+			redacted := redactor.Redact(session)
+			if actual := paths(redacted); !slices.Equal(actual, tc.expectedPaths) {
+				t.Errorf("expected the paths %v, got %v", tc.expectedPaths, actual)
+			}
+			if actual := redacted.Repositories[0].Commits[0].Files; !slices.Equal(actual, tc.expectedCommits) {
+				t.Errorf("expected the committed files %v, got %v", tc.expectedCommits, actual)
+			}
+			if redacted.TotalTimeMs != session.TotalTimeMs || redacted.Repositories[0].DurationMs != session.Repositories[0].DurationMs {
+				t.Errorf("expected the durations to be kept")
+			}
+		})
+	}
+}
+
+func TestRedactorHashesPaths(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{{Repository: "*", Mode: pulse.RedactHash}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	session := redactionSession("hashed")
+	redacted := redactor.Redact(session)
+	for _, file := range redacted.Repositories[0].Files {
+		if strings.Contains(file.Path, "billing") || strings.Contains(file.Name, "invoice") {
+			t.Errorf("expected the path to be hashed, got %s", file.Path)
+		}
+	}
+
+	// The same path should always hash to the same value, which allows
+	// the files to be merged with the ones from previous sessions.
+	if !slices.Equal(paths(redacted), paths(redactor.Redact(session))) {
+		t.Error("expected the hashes to be stable")
+	}
+	if !slices.Contains(paths(redacted), redacted.Repositories[0].Commits[0].Files[0]) {
+		t.Error("expected the committed files to hash to the same paths as the files")
+	}
+	if len(session.Repositories[0].Files) != 3 || !strings.Contains(paths(session)[1], "billing") {
+		t.Error("expected the original session to be left untouched")
+	}
+
+	_, err = pulse.NewRedactor("", []pulse.RedactionRule{{Repository: "*", Mode: pulse.RedactHash}})
+	if !errors.Is(err, pulse.ErrMissingSecret) {
+		t.Errorf("expected hashing without a secret to fail, got %v", err)
+	}
+}
+
+func TestRedactorRepositoryOverride(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{{Repository: "*", Mode: pulse.RedactKeep}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The rule from the repository's configuration file can tighten the configured rules.
+	session := redactionSession("override")
+	session.Repositories[0].Redaction = &pulse.RedactionRule{Repository: "override", Mode: pulse.RedactCollapse, Depth: 1}
+	expected := []string{"override/*.markdown", "override/internal/*.go"}
+	if actual := paths(redactor.Redact(session)); !slices.Equal(actual, expected) {
+		t.Errorf("expected the paths %v, got %v", expected, actual)
+	}
+
+	// If the rule is invalid, only the filetypes should be kept.
+	session.Repositories[0].Redaction = &pulse.RedactionRule{Repository: "override", Mode: "secret"}
+	expected = []string{"override/*.go", "override/*.markdown"}
+	if actual := paths(redactor.Redact(session)); !slices.Equal(actual, expected) {
+		t.Errorf("expected the paths %v, got %v", expected, actual)
+	}
+}
+
+func TestRedactorRepositoryOverrideCantLoosenTheRules(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{
+		{Repository: "collapsed", Mode: pulse.RedactCollapse, Depth: 1},
+		{Repository: "filetype", Mode: pulse.RedactFiletype},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name       string
+		repository string
+		override   pulse.RedactionRule
+		expected   []string
+	}{
+		{
+			name:       "keep is looser than collapse",
+			repository: "collapsed",
+			override:   pulse.RedactionRule{Mode: pulse.RedactKeep},
+			expected:   []string{"collapsed/*.markdown", "collapsed/internal/*.go"},
+		},
+		{
+			name:       "a deeper collapse is looser",
+			repository: "collapsed",
+			override:   pulse.RedactionRule{Mode: pulse.RedactCollapse, Depth: 2},
+			expected:   []string{"collapsed/*.markdown", "collapsed/internal/*.go"},
+		},
+		{
+			name:       "a shallower collapse is stricter",
+			repository: "collapsed",
+			override:   pulse.RedactionRule{Mode: pulse.RedactCollapse, Depth: 0},
+			expected:   []string{"collapsed/*.go", "collapsed/*.markdown"},
+		},
+		{
+			name:       "hash is looser than filetype",
+			repository: "filetype",
+			override:   pulse.RedactionRule{Mode: pulse.RedactHash},
+			expected:   []string{"filetype/*.go", "filetype/*.markdown"},
+		},
+	}
+
+	for _, tc := range testCases {
+		session := redactionSession(tc.repository)
+		override := tc.override
+		override.Repository = tc.repository
+		session.Repositories[0].Redaction = &override
+		if actual := paths(redactor.Redact(session)); !slices.Equal(actual, tc.expected) {
+			t.Errorf("%s: expected the paths %v, got %v", tc.name, tc.expected, actual)
+		}
+	}
+}
+
+func TestRedactorRedactsProjects(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{{Repository: "*", Mode: pulse.RedactCollapse, Depth: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf := pulse.Buffer{
+		Filename:   "main.go",
+		Filepath:   "monorepo/services/billing/main.go",
+		Filetype:   "go",
+		Repository: "monorepo",
+		Project:    "services/billing",
+		Duration:   time.Minute,
+	}
+	session := pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{buf}, pulse.Commits{}, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))[0]
+	repo := redactor.Redact(session).Repositories[0]
+	if len(repo.Projects) != 1 || repo.Projects[0].Name != "services" {
+		t.Fatalf("expected the project to be collapsed to services, got %+v", repo.Projects)
+	}
+	if files := repo.Projects[0].Files; len(files) != 1 || files[0].Path != "monorepo/services/*.go" {
+		t.Errorf("expected the file of the project to be collapsed, got %+v", files)
+	}
+	if len(repo.Files) != 0 {
+		t.Errorf("expected the repository to have no files outside of the project, got %+v", repo.Files)
+	}
+
+	// The files of a project that nothing is left of are moved to the repository.
+	redactor, err = pulse.NewRedactor("secret", []pulse.RedactionRule{{Repository: "*", Mode: pulse.RedactFiletype}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo = redactor.Redact(session).Repositories[0]
+	if len(repo.Projects) != 0 {
+		t.Errorf("expected the project to be dropped, got %+v", repo.Projects)
+	}
+	if len(repo.Files) != 1 || repo.Files[0].Path != "monorepo/*.go" {
+		t.Errorf("expected the file to be moved to the repository, got %+v", repo.Files)
+	}
+}
+
+func TestRedactorRedactsBranchesAndTickets(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{
+		{Repository: "hashed", Mode: pulse.RedactHash},
+		{Repository: "collapsed", Mode: pulse.RedactCollapse, Depth: 1},
+		{Repository: "client-*", Mode: pulse.RedactFiletype},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		repository string
+		kept       bool
+		dropped    bool
+	}{
+		{repository: "kept", kept: true},
+		{repository: "hashed"},
+		{repository: "collapsed", dropped: true},
+		{repository: "client-acme", dropped: true},
+	}
+
+	for _, tc := range testCases {
+		redacted := redactor.Redact(redactionSession(tc.repository))
+		repo := redacted.Repositories[0]
+		names := []string{repo.Commits[0].Branch}
+		for _, branch := range repo.Branches {
+			names = append(names, branch.Name)
+		}
+		for _, ticket := range redacted.Tickets {
+			names = append(names, ticket.ID)
+		}
+
+		switch {
+		case tc.kept:
+			expected := []string{"PULSE-12-invoices", "PULSE-12-invoices", "PULSE-12"}
+			if !slices.Equal(names, expected) {
+				t.Errorf("%s: expected the names %v, got %v", tc.repository, expected, names)
+			}
+		case tc.dropped:
+			if len(repo.Branches) != 0 || len(redacted.Tickets) != 0 || repo.Commits[0].Branch != "" {
+				t.Errorf("%s: expected the branches and tickets to be dropped, got %v", tc.repository, names)
+			}
+		default:
+			if len(names) != 3 || slices.ContainsFunc(names, func(name string) bool {
+				return name == "" || strings.Contains(name, "PULSE")
+			}) {
+				t.Errorf("%s: expected the branches and tickets to be hashed, got %v", tc.repository, names)
+			}
+			if names[0] != names[1] {
+				t.Errorf("%s: expected the branch of the commit to hash like the branch, got %v", tc.repository, names)
+			}
+		}
+	}
+}
+
+func TestRedactorRedactsTicketsByTheirStrictestRepository(t *testing.T) {
+	t.Parallel()
+
+	redactor, err := pulse.NewRedactor("secret", []pulse.RedactionRule{{Repository: "client-*", Mode: pulse.RedactFiletype}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buffers := pulse.Buffers{
+		{Filepath: "pulse/main.go", Repository: "pulse", Tickets: []string{"PULSE-12"}, Duration: time.Minute},
+		{Filepath: "client-acme/main.go", Repository: "client-acme", Tickets: []string{"PULSE-12"}, Duration: time.Minute},
+		{Filepath: "pulse/README.md", Repository: "pulse", Tickets: []string{"PULSE-13"}, Duration: time.Minute},
+	}
+	session := pulse.NewCodingSessions(pulse.Calendar{}, buffers, pulse.Commits{}, time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))[0]
+	tickets := redactor.Redact(session).Tickets
+	if len(tickets) != 1 || tickets[0].ID != "PULSE-13" {
+		t.Errorf("expected only the ticket of the kept repository to be left, got %+v", tickets)
+	}
+}
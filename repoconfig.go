@@ -0,0 +1,49 @@
+package pulse
+
+import (
+	"bytes"
+
+	"github.com/spf13/viper"
+)
+
+// RepositoryConfigFilename is the name of the file, at the root of
+// a repository, that can override how the repository is tracked.
+const RepositoryConfigFilename = ".pulse.yaml"
+
+// RepositoryConfig holds the overrides from a repository's configuration file.
+type RepositoryConfig struct {
+	// Name replaces the name that is parsed from the remote.
+	Name string
+	// Category groups the repository with others, e.g. work or personal.
+	Category string
+	// Ignore holds path patterns that work like those in a .pulseignore file.
+	Ignore []string
+	// Privacy is the mode that the paths of the repository are redacted with,
+	// if it is at least as strict as the configured rules.
+	Privacy string
+	// Depth is the number of directories that are kept in the collapse mode.
+	Depth int
+}
+
+// ParseRepositoryConfig parses the content of a repository's configuration file.
+func ParseRepositoryConfig(content []byte) (RepositoryConfig, error) {
+	v := viper.New()
+	v.SetConfigType("yaml")
+	err := v.ReadConfig(bytes.NewReader(content))
+	if err != nil {
+		return RepositoryConfig{}, err
+	}
+
+	var cfg RepositoryConfig
+	err = v.Unmarshal(&cfg)
+	return cfg, err
+}
+
+// RedactionRule returns the rule for the privacy level of the repository,
+// and false if the repository doesn't override the configured rules.
+func (c RepositoryConfig) RedactionRule(repository string) (*RedactionRule, bool) {
+	if c.Privacy == "" {
+		return nil, false
+	}
+	return &RedactionRule{Repository: repository, Mode: c.Privacy, Depth: c.Depth}, true
+}
@@ -4,19 +4,46 @@ import "cmp"
 
 // Repository represents a git repository. A coding session
 // might open files across any number of repos. The files of
-// the coding session are later grouped by repository.
+// the coding session are later grouped by repository. Files
+// that aren't under source control are grouped by workspace.
+// The files of a monorepo are further grouped by their project,
+// and Files only keeps the ones that are outside of any project.
 type Repository struct {
-	Name       string `bson:"name"`
-	Files      Files  `bson:"files"`
-	DurationMs int64  `bson:"duration_ms"`
+	Name         string   `bson:"name"`
+	Workspace    bool     `bson:"workspace,omitempty"`
+	Category     string   `bson:"category,omitempty"`
+	Files        Files    `bson:"files"`
+	Projects     Projects `bson:"projects,omitempty"`
+	Branches     Branches `bson:"branches"`
+	Commits      Commits  `bson:"commits"`
+	DurationMs   int64    `bson:"duration_ms"`
+	EditingMs    int64    `bson:"editing_ms"`
+	ReadingMs    int64    `bson:"reading_ms"`
+	Writes       int      `bson:"writes"`
+	LinesAdded   int      `bson:"lines_added"`
+	LinesRemoved int      `bson:"lines_removed"`
+	// Redaction overrides the configured redaction rules. It's
+	// only used by the redactor, and never leaves the machine.
+	Redaction *RedactionRule `bson:"-" json:"-"`
 }
 
 // merge takes two repositories, merges them, and returns the result.
 func (r Repository) merge(b Repository) Repository {
 	return Repository{
-		Name:       cmp.Or(r.Name, b.Name),
-		Files:      r.Files.merge(b.Files),
-		DurationMs: r.DurationMs + b.DurationMs,
+		Name:         cmp.Or(r.Name, b.Name),
+		Workspace:    r.Workspace || b.Workspace,
+		Category:     cmp.Or(r.Category, b.Category),
+		Files:        r.Files.merge(b.Files),
+		Projects:     r.Projects.merge(b.Projects),
+		Branches:     r.Branches.merge(b.Branches),
+		Commits:      r.Commits.merge(b.Commits),
+		DurationMs:   r.DurationMs + b.DurationMs,
+		EditingMs:    r.EditingMs + b.EditingMs,
+		ReadingMs:    r.ReadingMs + b.ReadingMs,
+		Writes:       r.Writes + b.Writes,
+		LinesAdded:   r.LinesAdded + b.LinesAdded,
+		LinesRemoved: r.LinesRemoved + b.LinesRemoved,
+		Redaction:    cmp.Or(r.Redaction, b.Redaction),
 	}
 }
 
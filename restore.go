@@ -8,6 +8,8 @@ import (
 )
 
 // getSegmentPaths returns a sorted list of every segments log file in the directory.
+// Every file is considered to be a segment. Anything else that is stored alongside
+// the segments, e.g. the outbox and the journal, has to be kept in a subdirectory.
 func getSegmentPaths(dirPath string) ([]string, error) {
 	entries, err := os.ReadDir(dirPath)
 	if err != nil {
@@ -3,22 +3,36 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"strings"
 	"time"
 
 	"github.com/creativecreature/pulse"
 )
 
-const aggregationInterval = 10 * time.Minute
+const defaultAggregationInterval = 10 * time.Minute
 
-// writeToRemote will write the session to the remote storage.
+// writeToRemote will write the session to the remote storage. Sessions
+// that we fail to write are added to the outbox, and retried later.
 func (s *Server) writeToRemote(session pulse.CodingSession) {
 	if len(session.Repositories) == 0 {
 		return
 	}
 
-	err := s.sessionWriter.Write(context.Background(), session)
+	// We can't tell if the time that we read for today includes
+	// the sessions that we've written while it was being read.
+	select {
+	case <-s.remoteLoadedCh:
+	case <-s.writesCtx.Done():
+	}
+
+	ctx, cancel := context.WithTimeout(s.writesCtx, remoteWriteTimeout)
+	defer cancel()
+	err := s.sessionWriter.Write(ctx, session)
 	if err != nil {
 		s.log.Errorf("Failed to write the session to the permanent storage: %v", err)
+		if outboxErr := s.outbox.add(session, s.clock.Now()); outboxErr != nil {
+			s.log.Errorf("Failed to add the session to the outbox: %v", outboxErr)
+		}
 	}
 }
 
@@ -26,28 +40,64 @@ func (s *Server) aggregate() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	buffers := make(pulse.Buffers, 0)
+	buffers, commits := make(pulse.Buffers, 0), make(pulse.Commits, 0)
 	values := s.db.Aggregate()
-	for _, value := range values {
-		var buf pulse.Buffer
-		err := json.Unmarshal(value, &buf)
-		if err != nil {
-			panic(err)
+	s.logVersion++
+	for key, value := range values {
+		switch {
+		case strings.HasPrefix(key, uncommittedKeyPrefix), strings.HasPrefix(key, hookKeyPrefix):
+			// The time that has been spent on uncommitted files has to survive
+			// until they're eventually committed. The aggregation removes every
+			// key from the log, which is how the committed and stale files are deleted.
+			if s.isStale(key, value) {
+				continue
+			}
+			if err := s.db.Set(key, value); err != nil {
+				s.failedWrites.Add(1)
+				s.log.Error("Failed to keep the uncommitted time", "key", key, "err", err)
+			}
+		case strings.HasPrefix(key, commitKeyPrefix):
+			var commit pulse.Commit
+			if err := json.Unmarshal(value, &commit); err != nil {
+				s.quarantine(key, value, err)
+				continue
+			}
+			commits = append(commits, commit)
+		default:
+			var buf pulse.Buffer
+			if err := json.Unmarshal(value, &buf); err != nil {
+				s.quarantine(key, value, err)
+				continue
+			}
+			buffers = append(buffers, buf)
 		}
-		buffers = append(buffers, buf)
 	}
-	codingSession := pulse.NewCodingSession(buffers, s.clock.Now())
-	go s.writeToRemote(codingSession)
+	// The paths are redacted before the sessions leave the machine. The
+	// buffers in the log are kept as they are, for the local reports.
+	// Buffers from the previous days are written to sessions of their own.
+	for _, session := range pulse.NewCodingSessions(s.calendar, buffers, commits, s.clock.Now()) {
+		s.addAggregated(session)
+		session.WriteIDs = []string{newWriteID()}
+		codingSession := s.redactor.Redact(session)
+		s.writes.Add(1)
+		go func() {
+			defer s.writes.Done()
+			s.writeToRemote(codingSession)
+		}()
+	}
 }
 
 func (s *Server) runAggregations(ctx context.Context) {
 	go func() {
-		ticker, stopTicker := s.clock.NewTicker(aggregationInterval)
-		defer stopTicker()
+		ticker, stopTicker := s.clock.NewTicker(s.interval(&s.aggregationInterval))
+		defer func() { stopTicker() }()
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-s.aggregationIntervalChanged:
+				stopTicker()
+				ticker, stopTicker = s.clock.NewTicker(s.interval(&s.aggregationInterval))
 			case <-ticker:
 				s.aggregate()
 			}
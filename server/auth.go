@@ -0,0 +1,29 @@
+package server
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/creativecreature/pulse"
+)
+
+// authenticate wraps the handler and rejects every connection that doesn't
+// present the configured token. The token is checked once per connection,
+// before any of the calls that are made on it are dispatched to the proxy.
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(pulse.TokenHeader)
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			s.log.Warn("Rejected a connection with an invalid token", "remote_addr", r.RemoteAddr)
+			http.Error(w, "invalid or missing token", http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
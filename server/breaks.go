@@ -0,0 +1,41 @@
+package server
+
+import "time"
+
+// defaultBreakGap is the amount of time without any activity that counts as a break.
+const defaultBreakGap = 5 * time.Minute
+
+// trackActivity records activity at the given time. A gap in the activity that
+// is long enough to count as a break starts a new stretch of continuous
+// activity. It replaces the time of the last heartbeat. Should be called with a lock.
+func (s *Server) trackActivity(now time.Time) {
+	if s.activeSince.IsZero() || now.Sub(s.lastHeartbeat) >= s.breakGap {
+		s.activeSince = now
+	}
+	s.lastHeartbeat = now
+}
+
+// Break returns the amount of time that we've been continuously active, if
+// it has exceeded the threshold without a break. Zero is returned otherwise.
+// Once we've been reminded, it's not returned again until another threshold
+// has elapsed. No reminders are given if the threshold hasn't been configured.
+func (s *Server) Break() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	if s.breakThreshold == 0 || s.activeSince.IsZero() || now.Sub(s.lastHeartbeat) >= s.breakGap {
+		return 0
+	}
+
+	since := s.activeSince
+	if s.lastBreakReminder.After(since) {
+		since = s.lastBreakReminder
+	}
+	if now.Sub(since) < s.breakThreshold {
+		return 0
+	}
+
+	s.lastBreakReminder = now
+	return now.Sub(s.activeSince)
+}
@@ -0,0 +1,178 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+const (
+	// commitKeyPrefix is the prefix of the keys that commits are stored under.
+	commitKeyPrefix = "commit_"
+	// uncommittedKeyPrefix is the prefix of the keys that hold the time
+	// that has been spent on a file since it was last committed.
+	uncommittedKeyPrefix = "uncommitted_"
+	// hookKeyPrefix is the prefix of the keys that hold the time at which a
+	// repository last sent a commit. We only keep track of the uncommitted
+	// time of the repositories that have a post-commit hook.
+	hookKeyPrefix = "hook_"
+	// maxUncommittedAge is how long we keep the uncommitted time of a file
+	// that isn't worked on, and the hook of a repository that doesn't send
+	// any commits. Files that are only read are never committed, for example.
+	maxUncommittedAge = 30 * 24 * time.Hour
+)
+
+// uncommittedEntry is the time that has been spent on a file since it was
+// last committed, and the time at which it was last worked on.
+type uncommittedEntry struct {
+	Duration  time.Duration `json:"duration"`
+	TouchedAt time.Time     `json:"touched_at"`
+}
+
+// storedUncommittedTime returns the time that has been spent on a file since
+// it was last committed. Should be called with a lock.
+func (s *Server) storedUncommittedTime(key string) uncommittedEntry {
+	bytes, ok := s.db.Get(key)
+	if !ok {
+		return uncommittedEntry{}
+	}
+	return s.uncommittedTime(key, bytes)
+}
+
+// uncommittedTime decodes the time that has been spent on a file since it was
+// last committed. Corrupt values are quarantined. Should be called with a lock.
+func (s *Server) uncommittedTime(key string, bytes []byte) uncommittedEntry {
+	var entry uncommittedEntry
+	if err := json.Unmarshal(bytes, &entry); err == nil {
+		return entry
+	}
+
+	// The entries used to hold nothing but the duration.
+	if err := json.Unmarshal(bytes, &entry.Duration); err != nil {
+		s.quarantine(key, bytes, err)
+		return uncommittedEntry{}
+	}
+	entry.TouchedAt = s.clock.Now()
+	return entry
+}
+
+// hasCommitHook returns true if the repository has sent a commit
+// within the maximum uncommitted age. Should be called with a lock.
+func (s *Server) hasCommitHook(repository string) bool {
+	_, ok := s.db.Get(hookKeyPrefix + repository)
+	return ok
+}
+
+// addUncommittedTime adds to the time that has been spent on the file of the
+// buffer since it was last committed. Files that can't be committed, and the
+// files of repositories without a post-commit hook, are skipped. Should be
+// called with a lock.
+func (s *Server) addUncommittedTime(buf pulse.Buffer, duration time.Duration) error {
+	if buf.Workspace || duration == 0 || !s.hasCommitHook(buf.Repository) {
+		return nil
+	}
+
+	key := uncommittedKeyPrefix + buf.Filepath
+	entry := s.storedUncommittedTime(key)
+	entry.Duration += duration
+	entry.TouchedAt = s.clock.Now()
+	bytes, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.Set(key, bytes)
+}
+
+// takeUncommittedTime returns the time that has been spent on a file since
+// it was last committed, and resets it. Should be called with a lock.
+func (s *Server) takeUncommittedTime(filepath string) (time.Duration, error) {
+	key := uncommittedKeyPrefix + filepath
+	entry := s.storedUncommittedTime(key)
+	if entry.Duration == 0 {
+		return 0, nil
+	}
+
+	reset, err := json.Marshal(uncommittedEntry{TouchedAt: s.clock.Now()})
+	if err != nil {
+		return 0, err
+	}
+	return entry.Duration, s.db.Set(key, reset)
+}
+
+// isStale returns true if an entry in the log with the uncommitted time of a
+// file, or the hook of a repository, should be dropped by the aggregation.
+// Should be called with a lock.
+func (s *Server) isStale(key string, value []byte) bool {
+	if strings.HasPrefix(key, hookKeyPrefix) {
+		var committedAt time.Time
+		if err := json.Unmarshal(value, &committedAt); err != nil {
+			s.quarantine(key, value, err)
+			return true
+		}
+		return s.clock.Now().Sub(committedAt) > maxUncommittedAge
+	}
+
+	entry := s.uncommittedTime(key, value)
+	return entry.Duration == 0 || s.clock.Now().Sub(entry.TouchedAt) > maxUncommittedAge
+}
+
+// Commit is invoked by the post-commit hook. The commit is attributed the time
+// that was spent on its files since they were last committed, and stored until
+// the next aggregation.
+func (s *Server) Commit(commit pulse.Commit, reply *string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.journal(methodCommit, pulse.Event{}, &commit)
+
+	s.log.Debug("Received Commit event",
+		"repository", commit.Repository,
+		"branch", commit.Branch,
+		"sha", commit.SHA,
+	)
+
+	// If one of the committed files is open, we'll save the time that has been
+	// spent on it so far, and then start counting towards the next commit.
+	if s.activeBuffer != nil && slices.Contains(commit.Files, s.activeBuffer.Filepath) {
+		path := s.activePath
+		if err := s.saveBuffer(); err != nil {
+			s.log.Error("Failed to save the active buffer", "err", err)
+		}
+		if err := s.openFile(pulse.Event{Path: path}); err != nil {
+			s.log.Error("Failed to reopen the active buffer", "err", err)
+		}
+	}
+
+	// The time that is spent on the repository from now on is
+	// tracked until its files are committed.
+	if bytes, err := json.Marshal(s.clock.Now()); err == nil {
+		if err = s.db.Set(hookKeyPrefix+commit.Repository, bytes); err != nil {
+			s.log.Error("Failed to store the commit hook", "repository", commit.Repository, "err", err)
+		}
+	}
+
+	var duration time.Duration
+	for _, file := range commit.Files {
+		d, err := s.takeUncommittedTime(file)
+		if err != nil {
+			s.log.Error("Failed to reset the uncommitted time", "file", file, "err", err)
+		}
+		duration += d
+	}
+	commit.DurationMs = duration.Milliseconds()
+
+	bytes, err := json.Marshal(commit)
+	if err == nil {
+		err = s.db.Set(commitKeyPrefix+commit.SHA, bytes)
+	}
+	if err != nil {
+		s.failedWrites.Add(1)
+		return fmt.Errorf("failed to store the commit %s: %w", commit.SHA, err)
+	}
+
+	*reply = "Successfully stored the commit"
+	return nil
+}
@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+
+	"github.com/creativecreature/pulse"
+)
+
+// goalHistory holds the daily summaries of the days from one date, and up until another.
+type goalHistory struct {
+	date string
+	from string
+	days []pulse.Summary
+}
+
+// history returns the daily summaries of the days from the date, and up until
+// today. They're read from the permanent storage the first time they're needed
+// each day, and again if an earlier date is needed. The time that is tracked
+// today is added to today's summary instead.
+func (s *Server) history(ctx context.Context, cal pulse.Calendar, today, from string) ([]pulse.Summary, error) {
+	s.historyMu.Lock()
+	defer s.historyMu.Unlock()
+
+	if s.sessionReader != nil && (s.goalHistory.date != today || s.goalHistory.from > from) {
+		minDate, err := cal.ParseDate(from)
+		if err != nil {
+			return nil, err
+		}
+		maxDate, err := cal.ParseDate(today)
+		if err != nil {
+			return nil, err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, remoteReadTimeout)
+		defer cancel()
+		sessions, err := s.sessionReader.ReadRange(ctx, minDate.UnixMilli(), maxDate.UnixMilli()-1)
+		if err != nil {
+			return nil, err
+		}
+
+		days := make([]pulse.Summary, 0, len(sessions))
+		for _, session := range sessions {
+			day := pulse.NewSummary(session.DateString)
+			day.AddSession(session)
+			days = append(days, day)
+		}
+		s.goalHistory = goalHistory{date: today, from: from, days: days}
+	}
+
+	// The days that we've read could go further back than what was asked for.
+	days := make([]pulse.Summary, 0, len(s.goalHistory.days))
+	for _, day := range s.goalHistory.days {
+		if day.DateString >= from {
+			days = append(days, day)
+		}
+	}
+	return days, nil
+}
+
+// Goals returns the progress towards the configured goals. It combines the
+// time that has been tracked today with the days in the permanent storage.
+func (s *Server) Goals(ctx context.Context) ([]pulse.GoalProgress, error) {
+	var today pulse.Summary
+	s.Today(&today)
+
+	s.mu.Lock()
+	goals, calendar := s.goals, s.calendar
+	s.mu.Unlock()
+
+	return pulse.EvaluateGoalsWithHistory(goals, today, func(from string) ([]pulse.Summary, error) {
+		return s.history(ctx, calendar, today.DateString, from)
+	})
+}
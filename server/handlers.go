@@ -5,11 +5,12 @@ import (
 )
 
 // FocusGained is invoked by the FocusGained autocommand.
-func (s *Server) FocusGained(event pulse.Event, reply *string) {
+func (s *Server) FocusGained(event pulse.Event, reply *string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.journal(methodFocusGained, event, nil)
 
-	s.lastHeartbeat = s.clock.Now()
+	s.trackActivity(s.clock.Now())
 	s.log.Debug("Received FocusGained event",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
@@ -17,19 +18,23 @@ func (s *Server) FocusGained(event pulse.Event, reply *string) {
 	)
 
 	if event.Path == "" {
-		return
+		return nil
 	}
 
-	s.openFile(event)
+	if err := s.openFile(event); err != nil {
+		return err
+	}
 	*reply = "Successfully updated the client being focused"
+	return nil
 }
 
 // OpenFile gets invoked by the *BufEnter* autocommand.
-func (s *Server) OpenFile(event pulse.Event, reply *string) {
+func (s *Server) OpenFile(event pulse.Event, reply *string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.journal(methodOpenFile, event, nil)
 
-	s.lastHeartbeat = s.clock.Now()
+	s.trackActivity(s.clock.Now())
 	s.log.Debug("Received OpenFile event",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
@@ -37,39 +42,63 @@ func (s *Server) OpenFile(event pulse.Event, reply *string) {
 	)
 
 	if event.Path == "" {
-		return
+		return nil
 	}
 
-	s.openFile(event)
+	if err := s.openFile(event); err != nil {
+		return err
+	}
 	*reply = "Successfully updated the current file"
+	return nil
 }
 
 // SendHeartbeat can be called for events such as buffer writes and cursor moves.
 // Its purpose is to notify the server that the current session remains active.
 // The server ends the session if it doesn't receive a heartbeat for 10 minutes.
-func (s *Server) SendHeartbeat(event pulse.Event, reply *string) {
+// Text changes and writes are recorded on the active buffer, which allows us to
+// tell the time spent editing apart from the time spent reading. They're only
+// recorded if they were made to the file of the active buffer. Another editor,
+// or a split window, could otherwise have its edits credited to the wrong file.
+func (s *Server) SendHeartbeat(event pulse.Event, reply *string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.journal(methodSendHeartbeat, event, nil)
 
-	s.lastHeartbeat = s.clock.Now()
+	s.trackActivity(s.clock.Now())
 	s.log.Debug("Received heartbeat",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
 		"os", event.OS,
+		"type", event.Type,
 	)
+
+	if s.activeBuffer != nil && event.Path == s.activePath {
+		switch event.Type {
+		case pulse.TextChanged:
+			s.activeBuffer.Edit(s.lastHeartbeat)
+		case pulse.Write:
+			s.activeBuffer.Write()
+		case pulse.Heartbeat, pulse.CursorMoved:
+		}
+	}
 	*reply = "Successfully sent heartbeat"
+	return nil
 }
 
 // EndSession should be called by the *VimLeave* autocommand.
-func (s *Server) EndSession(event pulse.Event, reply *string) {
+func (s *Server) EndSession(event pulse.Event, reply *string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.journal(methodEndSession, event, nil)
 
 	s.log.Debug("Received EndSession event",
 		"editor_id", event.EditorID,
 		"editor", event.Editor,
 		"os", event.OS,
 	)
-	s.saveBuffer()
+	if err := s.saveBuffer(); err != nil {
+		return err
+	}
 	*reply = "The session was ended successfully"
+	return nil
 }
@@ -7,12 +7,16 @@ import (
 )
 
 const (
-	HeartbeatTTL      = time.Minute * 10
-	heartbeatInterval = time.Second * 10
+	// HeartbeatTTL is the default amount of time without any heartbeats
+	// before a buffer expires. It's overridden by server.heartbeatTTL.
+	HeartbeatTTL             = time.Minute * 10
+	defaultHeartbeatInterval = time.Second * 10
 )
 
 // CheckHeartbeat is used to check if the session has been inactive for more than
 // ten minutes. If that is the case, the session will be terminated and saved to disk.
+// The buffer is closed at the time of the last heartbeat plus the grace period, so
+// that the idle time isn't credited to the last file.
 func (s *Server) checkHeartbeat() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -26,14 +30,16 @@ func (s *Server) checkHeartbeat() {
 		return
 	}
 
-	if s.clock.Now().After(s.lastHeartbeat.Add(HeartbeatTTL)) {
+	if s.clock.Now().After(s.lastHeartbeat.Add(s.heartbeatTTL)) {
 		s.log.Info(
 			"Writing the current buffer to disk due to inactivity",
 			"last_heartbeat", strconv.FormatInt(s.lastHeartbeat.UnixMilli(), 10),
 			"current_time", strconv.FormatInt(s.clock.Now().UnixMilli(), 10),
-			"end_time", strconv.FormatInt(s.lastHeartbeat.Add(HeartbeatTTL).UnixMilli(), 10),
+			"end_time", strconv.FormatInt(s.lastHeartbeat.Add(s.idleGracePeriod).UnixMilli(), 10),
 		)
-		s.saveBuffer()
+		if err := s.expireBuffer(); err != nil {
+			s.log.Error("Failed to expire the buffer", "err", err)
+		}
 	}
 }
 
@@ -41,12 +47,15 @@ func (s *Server) checkHeartbeat() {
 // that no session is allowed to be idle for more than 10 minutes.
 func (s *Server) runHeartbeatChecks(ctx context.Context) {
 	go func() {
-		ticker, stopTicker := s.clock.NewTicker(heartbeatInterval)
-		defer stopTicker()
+		ticker, stopTicker := s.clock.NewTicker(s.interval(&s.heartbeatInterval))
+		defer func() { stopTicker() }()
 		for {
 			select {
 			case <-ctx.Done():
 				return
+			case <-s.heartbeatIntervalChanged:
+				stopTicker()
+				ticker, stopTicker = s.clock.NewTicker(s.interval(&s.heartbeatInterval))
 			case <-ticker:
 				s.checkHeartbeat()
 			}
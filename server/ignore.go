@@ -0,0 +1,49 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+// ignoreFile holds the patterns of a repository's ignore file.
+type ignoreFile struct {
+	modTime  time.Time
+	patterns []string
+}
+
+// ignores reports whether the file is ignored by the configuration, or by
+// the ignore or configuration file of its repository. Should be called with a lock.
+func (s *Server) ignores(file pulse.GitFile) bool {
+	rules := s.ignoreRules.With(s.ignoreFilePatterns(file.Root)).With(file.Config.Ignore)
+	return rules.Ignores(file)
+}
+
+// ignoreFilePatterns returns the patterns of the repository's ignore file. The
+// file is only parsed again if it has been modified. Should be called with a lock.
+func (s *Server) ignoreFilePatterns(root string) []string {
+	if root == "" {
+		return nil
+	}
+
+	path := filepath.Join(root, pulse.IgnoreFilename)
+	info, err := os.Stat(path)
+	if err != nil {
+		delete(s.ignoreFiles, root)
+		return nil
+	}
+
+	if cached, ok := s.ignoreFiles[root]; ok && cached.modTime.Equal(info.ModTime()) {
+		return cached.patterns
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	patterns := pulse.ParseIgnoreFile(content)
+	s.ignoreFiles[root] = ignoreFile{modTime: info.ModTime(), patterns: patterns}
+	return patterns
+}
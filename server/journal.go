@@ -0,0 +1,185 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+// JournalDir is the directory within the segment directory where we journal the events.
+const JournalDir = "journal"
+
+// defaultJournalDays is the number of days that the journal is kept for.
+const defaultJournalDays = 30
+
+// The methods that can be found in the journal.
+const (
+	methodFocusGained   = "FocusGained"
+	methodOpenFile      = "OpenFile"
+	methodSendHeartbeat = "SendHeartbeat"
+	methodCommit        = "Commit"
+	methodEndSession    = "EndSession"
+)
+
+// JournalEntry is an event that the server received, along with the time it was received.
+type JournalEntry struct {
+	Time   time.Time     `json:"time"`
+	Method string        `json:"method"`
+	Event  pulse.Event   `json:"event"`
+	Commit *pulse.Commit `json:"commit,omitempty"`
+}
+
+// journal appends the events that the server receives to a file per day. The
+// files of the days that are older than the retention are removed as the files
+// of new days are opened. A nil journal discards the events.
+type journal struct {
+	mu       sync.Mutex
+	dir      string
+	calendar pulse.Calendar
+	days     int
+	date     string
+	file     *os.File
+}
+
+func newJournal(dir string) *journal {
+	return &journal{dir: dir, days: defaultJournalDays}
+}
+
+// configure sets the calendar that the files are named by, and the number
+// of days that the journal is kept for.
+func (j *journal) configure(cal pulse.Calendar, days int) {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.calendar, j.days = cal, days
+}
+
+// append writes the entry to the file of the day that it was received.
+func (j *journal) append(entry JournalEntry) error {
+	if j == nil {
+		return nil
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	date := j.calendar.DateString(entry.Time)
+	if j.file == nil || j.date != date {
+		if j.file != nil {
+			j.file.Close()
+		}
+		err = os.MkdirAll(j.dir, 0o700)
+		if err != nil {
+			return err
+		}
+		j.file, err = os.OpenFile(filepath.Join(j.dir, date+".jsonl"), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			j.file = nil
+			return err
+		}
+		j.date = date
+		j.prune(j.calendar.DateString(entry.Time.AddDate(0, 0, -j.days)))
+	}
+
+	_, err = j.file.Write(append(data, '\n'))
+	return err
+}
+
+// prune removes the files of the days before the cutoff date. Should be called with a lock.
+func (j *journal) prune(cutoff string) {
+	entries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		date, ok := strings.CutSuffix(entry.Name(), ".jsonl")
+		if ok && date < cutoff {
+			os.Remove(filepath.Join(j.dir, entry.Name()))
+		}
+	}
+}
+
+// close closes the file of the current day.
+func (j *journal) close() {
+	if j == nil {
+		return
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// journal records an event that the server received. It's called with
+// the lock held, which keeps the entries in the order that the events
+// were applied. Failing to journal an event shouldn't prevent us from tracking it.
+func (s *Server) journal(method string, event pulse.Event, commit *pulse.Commit) {
+	entry := JournalEntry{Time: s.clock.Now(), Method: method, Event: event, Commit: commit}
+	if err := s.journalWriter.append(entry); err != nil {
+		s.log.Error("Failed to journal the event", "method", method, "err", err)
+	}
+}
+
+// ReadJournal reads the entries that were journaled between the from and to
+// dates of the calendar, both inclusive. The entries are returned in the
+// order they were received.
+func ReadJournal(cal pulse.Calendar, dir string, from, to time.Time) ([]JournalEntry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fromDate, toDate := cal.DateString(from), cal.DateString(to)
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		date, ok := strings.CutSuffix(dirEntry.Name(), ".jsonl")
+		if ok && date >= fromDate && date <= toDate {
+			names = append(names, dirEntry.Name())
+		}
+	}
+	slices.Sort(names)
+
+	entries := make([]JournalEntry, 0)
+	for _, name := range names {
+		entries, err = readJournalFile(filepath.Join(dir, name), entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// readJournalFile appends the entries of a journal file to the slice.
+func readJournalFile(path string, entries []JournalEntry) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var entry JournalEntry
+		// A line may have been cut short if the server was killed mid-write.
+		if json.Unmarshal(scanner.Bytes(), &entry) != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	return entries, scanner.Err()
+}
@@ -0,0 +1,145 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/creativecreature/pulse"
+)
+
+// maxLineCountSize is the size, in bytes, of the largest file that we'll count
+// the changed lines of. Larger files, e.g. logs and binaries, would take too
+// long to diff, and we'd have to keep a copy of them while the buffer is open.
+const maxLineCountSize = 1 << 20
+
+// errTooLarge is returned for files that are too large to count the changed lines of.
+var errTooLarge = errors.New("the file is too large to count the changed lines of")
+
+// maxQueuedLineJobs is the number of line jobs that can be waiting for the
+// background goroutine. Further jobs are dropped rather than blocking the RPC.
+const maxQueuedLineJobs = 64
+
+// lineJob asks the background goroutine to either take a snapshot of a file
+// that was opened, or to count the changed lines of a buffer that was closed.
+type lineJob struct {
+	buf    *pulse.Buffer
+	path   string
+	closed bool
+	// piece is written with the changed lines of a closed buffer.
+	piece pulse.Buffer
+}
+
+// snapshot is the content of the active buffer's file when it was opened.
+type snapshot struct {
+	buf     *pulse.Buffer
+	content []byte
+}
+
+// readContent reads the content of a file that is small enough to be diffed.
+func readContent(path string) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+	if info.Size() > maxLineCountSize {
+		return nil, errTooLarge
+	}
+
+	// The file could grow after we've checked its size.
+	content, err := io.ReadAll(io.LimitReader(file, maxLineCountSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxLineCountSize {
+		return nil, errTooLarge
+	}
+	return content, nil
+}
+
+// queueLineJob hands a job to the background goroutine. The file reads and
+// diffs could take a while, and we don't want the RPCs to wait for them.
+// Should be called with a lock.
+func (s *Server) queueLineJob(job lineJob) {
+	if s.lineJobsClosed {
+		return
+	}
+	select {
+	case s.lineJobs <- job:
+	default:
+		s.log.Debug("Too many queued line jobs, not counting the changed lines of the file", "path", job.path)
+	}
+}
+
+// runLineCounts takes the snapshots, and counts the changed lines, in the
+// order that the buffers were opened and closed. It returns once the
+// jobs have been closed, and the ones that were queued are done.
+func (s *Server) runLineCounts() {
+	defer close(s.lineCountsDone)
+	var active snapshot
+	for job := range s.lineJobs {
+		if !job.closed {
+			active = s.takeSnapshot(job)
+			continue
+		}
+		// The snapshot is missing if the file couldn't be read, or if its job was dropped.
+		if active.buf == job.buf {
+			s.countLines(job, active.content)
+		}
+		active = snapshot{}
+	}
+}
+
+// stopLineCounts waits for the queued line jobs, and stops the goroutine that runs them.
+func (s *Server) stopLineCounts() {
+	s.mu.Lock()
+	if !s.lineJobsClosed {
+		s.lineJobsClosed = true
+		close(s.lineJobs)
+	}
+	s.mu.Unlock()
+	<-s.lineCountsDone
+}
+
+// takeSnapshot keeps the content of the file that was opened, so that we're
+// able to count the lines that changed once the buffer closes. A file that
+// doesn't exist yet is considered to be empty.
+func (s *Server) takeSnapshot(job lineJob) snapshot {
+	content, err := readContent(job.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		s.log.Debug("Not counting the changed lines of the file", "path", job.path, "err", err)
+		return snapshot{}
+	}
+	return snapshot{buf: job.buf, content: content}
+}
+
+// countLines diffs the file of a closed buffer against the snapshot that was
+// taken when it was opened, and merges the changed lines with its entry.
+func (s *Server) countLines(job lineJob, before []byte) {
+	after, err := readContent(job.path)
+	if err != nil {
+		s.log.Debug("Failed to count the changed lines of the file", "path", job.path, "err", err)
+		return
+	}
+	added, removed := pulse.DiffLines(before, after)
+	if added == 0 && removed == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.piece.LinesAdded, job.piece.LinesRemoved = added, removed
+	if err := s.writePiece(job.piece); err != nil {
+		s.log.Error("Failed to write the changed lines", "path", job.path, "err", err)
+	}
+}
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrSocketInUse is returned if another server is listening on the unix socket.
+var ErrSocketInUse = errors.New("the socket is in use by another server")
+
+// removeStaleSocket removes a unix socket that has been left behind by a
+// server that didn't shut down cleanly. We only remove the socket if no
+// one is accepting connections on it. Anything else at the path, e.g. a
+// file that was configured as the socket by mistake, is left untouched.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("%s exists and isn't a socket", path)
+	}
+
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err == nil {
+		conn.Close()
+		return fmt.Errorf("%w: %s", ErrSocketInUse, path)
+	}
+
+	return os.Remove(path)
+}
+
+// listen creates a listener for the network and address. Unix
+// sockets are created with permissions for the user only.
+func listen(network, address string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, address)
+	}
+
+	err := os.MkdirAll(filepath.Dir(address), 0o700)
+	if err != nil {
+		return nil, err
+	}
+
+	err = removeStaleSocket(address)
+	if err != nil {
+		return nil, err
+	}
+
+	return listenUnix(address)
+}
+
+// connListener keeps track of the connections that it has accepted. The RPC
+// connections are hijacked from the HTTP server, which means that they aren't
+// closed when it shuts down.
+type connListener struct {
+	net.Listener
+	mu    sync.Mutex
+	conns map[net.Conn]struct{}
+}
+
+func newConnListener(listener net.Listener) *connListener {
+	return &connListener{Listener: listener, conns: make(map[net.Conn]struct{})}
+}
+
+// Accept waits for and returns the next connection to the listener.
+func (l *connListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	tracked := &trackedConn{Conn: conn}
+	tracked.onClose = func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		delete(l.conns, tracked)
+	}
+	l.conns[tracked] = struct{}{}
+	return tracked, nil
+}
+
+// closeConns closes every connection that is still open.
+func (l *connListener) closeConns() {
+	l.mu.Lock()
+	conns := make([]net.Conn, 0, len(l.conns))
+	for conn := range l.conns {
+		conns = append(conns, conn)
+	}
+	l.mu.Unlock()
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
+
+// trackedConn is a connection that lets the listener know when it's closed.
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	onClose func()
+}
+
+// Close closes the connection.
+func (c *trackedConn) Close() error {
+	c.once.Do(c.onClose)
+	return c.Conn.Close()
+}
@@ -0,0 +1,24 @@
+//go:build !unix
+
+package server
+
+import (
+	"net"
+	"os"
+)
+
+// listenUnix creates a unix socket, and restricts its permissions to the user.
+// There is no umask on these platforms, which is why it's done afterwards.
+func listenUnix(address string) (net.Listener, error) {
+	listener, err := net.Listen("unix", address)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(address, 0o600)
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	return listener, nil
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package server
+
+import (
+	"net"
+	"sync"
+	"syscall"
+)
+
+// umaskMu serializes the changes to the umask of the process.
+var umaskMu sync.Mutex
+
+// listenUnix creates a unix socket that only the user can connect to. The
+// umask is changed while the socket is created, because changing its
+// permissions afterwards leaves a window where anyone could connect. It's
+// shared by the whole process, which means that the files that are created
+// by other goroutines in the meantime are only accessible to the user too.
+func listenUnix(address string) (net.Listener, error) {
+	umaskMu.Lock()
+	defer umaskMu.Unlock()
+
+	previous := syscall.Umask(0o177)
+	defer syscall.Umask(previous)
+	return net.Listen("unix", address)
+}
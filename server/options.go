@@ -21,9 +21,26 @@ type FileReader interface {
 	GitFile(path string) (pulse.GitFile, error)
 }
 
+// WithSessionReader sets the reader that is used to seed the
+// time that has been written to the permanent storage today.
+func WithSessionReader(reader SessionReader) Option {
+	return func(a *Server) {
+		a.sessionReader = reader
+	}
+}
+
 // WithLog sets the logger used by the server.
 func WithLog(log *log.Logger) Option {
 	return func(a *Server) {
 		a.log = log
 	}
 }
+
+// withoutJournalAndOutbox leaves the server without a journal and an outbox.
+// It's used to replay the journal, which mustn't journal the replayed events
+// again, or hold on to the sessions that it computes.
+func withoutJournalAndOutbox() Option {
+	return func(a *Server) {
+		a.journalWriter, a.outbox = nil, nil
+	}
+}
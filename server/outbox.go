@@ -0,0 +1,190 @@
+package server
+
+import (
+	"context"
+	cryptorand "crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+const (
+	// outboxDir is the directory within the segment directory where we
+	// store the sessions that we failed to write to the permanent storage.
+	outboxDir = "outbox"
+	// minRetryInterval and maxRetryInterval bound the exponential
+	// backoff that we use when retrying the sessions in the outbox.
+	minRetryInterval = 30 * time.Second
+	maxRetryInterval = 30 * time.Minute
+	// remoteWriteTimeout is the maximum amount of time we'll wait for a write.
+	remoteWriteTimeout = 30 * time.Second
+)
+
+// outbox persists the coding sessions that we've failed to write to the
+// permanent storage. Each session is stored in a file of its own. A nil
+// outbox has no room for any sessions.
+type outbox struct {
+	mu  sync.Mutex
+	dir string
+	seq int
+}
+
+func newOutbox(dir string) *outbox {
+	return &outbox{dir: dir}
+}
+
+// errNoOutbox is returned when a session is added to a nil outbox.
+var errNoOutbox = errors.New("there is no outbox")
+
+// add writes the session to the outbox.
+func (o *outbox) add(session pulse.CodingSession, now time.Time) error {
+	if o == nil {
+		return errNoOutbox
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(o.dir, 0o700)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file first, to never leave a partial session behind.
+	o.seq++
+	name := fmt.Sprintf("%d_%d.json", now.UnixNano(), o.seq)
+	tmpPath := filepath.Join(o.dir, name+".tmp")
+	err = os.WriteFile(tmpPath, data, 0o600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, filepath.Join(o.dir, name))
+}
+
+// names returns the names of the sessions in the outbox, oldest first.
+func (o *outbox) names() []string {
+	if o == nil {
+		return nil
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entries, err := os.ReadDir(o.dir)
+	if err != nil {
+		return nil
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".json") {
+			names = append(names, entry.Name())
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// read returns the session with the given name.
+func (o *outbox) read(name string) (pulse.CodingSession, error) {
+	var session pulse.CodingSession
+	data, err := os.ReadFile(filepath.Join(o.dir, name))
+	if err != nil {
+		return session, err
+	}
+	err = json.Unmarshal(data, &session)
+	return session, err
+}
+
+// remove deletes the session with the given name from the outbox.
+func (o *outbox) remove(name string) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return os.Remove(filepath.Join(o.dir, name))
+}
+
+// newWriteID returns a random ID that identifies the write of a session. It's
+// kept when the session is added to the outbox, so that the permanent storage
+// is able to tell a retry apart from a new write.
+func newWriteID() string {
+	id := make([]byte, 16)
+	if _, err := cryptorand.Read(id); err != nil {
+		// The ID only has to be unique, which the time practically is.
+		return strconv.FormatInt(time.Now().UnixNano(), 36)
+	}
+	return hex.EncodeToString(id)
+}
+
+// withJitter returns a random duration in the range [d/2, d]. It prevents
+// the retries from being synchronized with other periodic work.
+func withJitter(d time.Duration) time.Duration {
+	//nolint: gosec // The jitter doesn't have to be cryptographically secure.
+	return d/2 + rand.N(d/2+1)
+}
+
+// OutboxSize returns the number of sessions that are waiting to be written to the permanent storage.
+func (s *Server) OutboxSize() int {
+	return len(s.outbox.names())
+}
+
+// flushOutbox tries to write every session in the outbox to the permanent
+// storage. It returns false if any of the writes failed.
+func (s *Server) flushOutbox(ctx context.Context) bool {
+	for _, name := range s.outbox.names() {
+		session, err := s.outbox.read(name)
+		if err != nil {
+			s.log.Error("Failed to read a session from the outbox", "name", name, "err", err)
+			continue
+		}
+
+		writeCtx, cancel := context.WithTimeout(ctx, remoteWriteTimeout)
+		err = s.sessionWriter.Write(writeCtx, session)
+		cancel()
+		if err != nil {
+			s.log.Warn("Failed to write a session from the outbox", "name", name, "err", err)
+			return false
+		}
+
+		err = s.outbox.remove(name)
+		if err != nil {
+			s.log.Error("Failed to remove a session from the outbox", "name", name, "err", err)
+		}
+	}
+	return true
+}
+
+// runOutbox retries the sessions in the outbox. The interval between the
+// retries grows exponentially for as long as the writes keep failing.
+func (s *Server) runOutbox(ctx context.Context) {
+	backoff := minRetryInterval
+	timer, stopTimer := s.clock.NewTimer(withJitter(backoff))
+	defer func() { stopTimer() }()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer:
+			if s.flushOutbox(ctx) {
+				backoff = minRetryInterval
+			} else {
+				backoff = min(backoff*2, maxRetryInterval)
+				s.log.Info("Retrying the outbox later", "sessions", s.OutboxSize(), "backoff", backoff)
+			}
+			timer, stopTimer = s.clock.NewTimer(withJitter(backoff))
+		}
+	}
+}
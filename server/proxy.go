@@ -1,6 +1,11 @@
 package server
 
-import "github.com/creativecreature/pulse"
+import (
+	"context"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
 
 // Proxy serves as the intermediary between our client and server. It directs
 // remote procedure calls to the server, mitigating the risk of unintentionally
@@ -16,15 +21,13 @@ func NewProxy(server *Server) *Proxy {
 
 // FocusGained should be called when a buffer gains focus.
 func (p *Proxy) FocusGained(event pulse.Event, reply *string) error {
-	p.server.FocusGained(event, reply)
-	return nil
+	return p.server.FocusGained(event, reply)
 }
 
 // OpenFile should be called when a buffer is opened.
 // The server will check if the path is a valid file.
 func (p *Proxy) OpenFile(event pulse.Event, reply *string) error {
-	p.server.OpenFile(event, reply)
-	return nil
+	return p.server.OpenFile(event, reply)
 }
 
 // SendHeartbeat can be called for events such as buffer writes
@@ -32,12 +35,53 @@ func (p *Proxy) OpenFile(event pulse.Event, reply *string) error {
 // the current session remains active. If we don't perform any
 // actions for 10 minutes the server is going to end the session.
 func (p *Proxy) SendHeartbeat(event pulse.Event, reply *string) error {
-	p.server.SendHeartbeat(event, reply)
+	return p.server.SendHeartbeat(event, reply)
+}
+
+// Commit should be called by the post-commit hook of a repository.
+func (p *Proxy) Commit(commit pulse.Commit, reply *string) error {
+	return p.server.Commit(commit, reply)
+}
+
+// Break returns the amount of time that we've been continuously
+// active, if it's time to take a break, and zero otherwise.
+func (p *Proxy) Break(event pulse.Event, reply *time.Duration) error {
+	*reply = p.server.Break()
+	return nil
+}
+
+// Today returns the time that has been tracked today. It doesn't modify any
+// state, which makes it cheap enough to be called from the statusline.
+func (p *Proxy) Today(event pulse.Event, reply *pulse.Summary) error {
+	p.server.Today(reply)
+	return nil
+}
+
+// Goals returns the progress towards the configured goals, and the streaks.
+func (p *Proxy) Goals(event pulse.Event, reply *[]pulse.GoalProgress) error {
+	goals, err := p.server.Goals(context.Background())
+	if err != nil {
+		return err
+	}
+	*reply = goals
+	return nil
+}
+
+// OutboxSize returns the number of sessions that are
+// waiting to be written to the permanent storage.
+func (p *Proxy) OutboxSize(event pulse.Event, reply *int) error {
+	*reply = p.server.OutboxSize()
+	return nil
+}
+
+// ErrorCounts returns the number of values that have been
+// quarantined, and the number of writes that have failed.
+func (p *Proxy) ErrorCounts(event pulse.Event, reply *ErrorCounts) error {
+	*reply = p.server.ErrorCounts()
 	return nil
 }
 
 // EndSession should be called when the neovim process ends.
 func (p *Proxy) EndSession(event pulse.Event, reply *string) error {
-	p.server.EndSession(event, reply)
-	return nil
+	return p.server.EndSession(event, reply)
 }
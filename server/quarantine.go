@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// quarantineDir is the directory within the segment directory where we keep
+// the values that couldn't be decoded.
+const quarantineDir = "quarantine"
+
+// ErrorCounts holds the number of times the server has run into bad data.
+type ErrorCounts struct {
+	// Quarantined is the number of values that couldn't be decoded.
+	Quarantined int64
+	// FailedWrites is the number of buffers and commits that couldn't be written to the log.
+	FailedWrites int64
+}
+
+// quarantinedValue is a value that couldn't be decoded, along with the reason.
+type quarantinedValue struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+	Error string `json:"error"`
+}
+
+// quarantine moves a value that couldn't be decoded out of the way. It's
+// written to a file of its own, which allows us to inspect it later.
+func (s *Server) quarantine(key string, value []byte, reason error) {
+	n := s.quarantined.Add(1)
+	s.log.Error("Quarantining a value that couldn't be decoded", "key", key, "err", reason)
+
+	data, err := json.Marshal(quarantinedValue{Key: key, Value: string(value), Error: reason.Error()})
+	if err == nil {
+		err = os.MkdirAll(s.quarantineDir, 0o700)
+	}
+	if err == nil {
+		name := fmt.Sprintf("%d_%d.json", s.clock.Now().UnixNano(), n)
+		err = os.WriteFile(filepath.Join(s.quarantineDir, name), data, 0o600)
+	}
+	if err != nil {
+		s.log.Error("Failed to write the value to the quarantine", "key", key, "err", err)
+	}
+}
+
+// ErrorCounts returns the number of values that have been
+// quarantined, and the number of writes that have failed.
+func (s *Server) ErrorCounts() ErrorCounts {
+	return ErrorCounts{
+		Quarantined:  s.quarantined.Load(),
+		FailedWrites: s.failedWrites.Load(),
+	}
+}
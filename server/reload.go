@@ -0,0 +1,121 @@
+package server
+
+import (
+	"cmp"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/creativecreature/pulse"
+)
+
+// interval reads one of the servers intervals while holding the lock.
+func (s *Server) interval(d *time.Duration) time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return *d
+}
+
+// notify signals a background job that its interval has changed.
+func notify(ch chan struct{}) {
+	select {
+	case ch <- struct{}{}:
+	default:
+	}
+}
+
+// applyConfig sets the timings, timezone, start of the day, break reminders,
+// ticket patterns, workspaces, goals, ignore, project, and redaction rules,
+// and log level of the server. Should be called with a lock.
+func (s *Server) applyConfig(cfg *pulse.Config) {
+	s.idleGracePeriod = cmp.Or(cfg.Server.IdleGracePeriod, defaultIdleGracePeriod)
+	s.heartbeatTTL = cmp.Or(cfg.Server.HeartbeatTTL, HeartbeatTTL)
+	s.heartbeatInterval = cmp.Or(cfg.Server.HeartbeatInterval, defaultHeartbeatInterval)
+	s.aggregationInterval = cmp.Or(cfg.Server.AggregationInterval, defaultAggregationInterval)
+	s.segmentationInterval = cmp.Or(cfg.Server.SegmentationInterval, defaultSegmentationInterval)
+	s.breakThreshold = cfg.Breaks.Threshold
+	s.breakGap = cmp.Or(cfg.Breaks.Gap, defaultBreakGap)
+
+	calendar, err := cfg.Calendar()
+	if err != nil {
+		s.log.Error("Failed to set the timezone and start of the day", "err", err)
+	} else {
+		s.calendar = calendar
+	}
+	s.journalWriter.configure(s.calendar, cmp.Or(cfg.Server.JournalDays, defaultJournalDays))
+
+	ticketPatterns, err := pulse.CompileTicketPatterns(cfg.Tickets.Patterns)
+	if err != nil {
+		s.log.Error("Failed to compile the ticket patterns", "err", err)
+	} else {
+		s.ticketPatterns = ticketPatterns
+	}
+
+	// If the redaction rules are invalid, we'll only keep the
+	// filetypes rather than risk sending paths that should be private.
+	redactor, err := pulse.NewRedactor(cfg.Privacy.Secret, cfg.Privacy.Rules)
+	if err != nil {
+		s.log.Error("Failed to create the redaction rules, only the filetypes will be kept", "err", err)
+		redactor, _ = pulse.NewRedactor("", []pulse.RedactionRule{{Repository: "*", Mode: pulse.RedactFiletype}})
+	}
+	s.redactor = redactor
+	s.workspaces = cfg.Workspaces
+
+	ignoreRules, err := pulse.NewIgnoreRules(cfg.Ignore.Repositories, cfg.Ignore.Paths, cfg.Ignore.Filetypes)
+	if err != nil {
+		s.log.Error("Failed to compile the ignore rules", "err", err)
+	} else {
+		s.ignoreRules = ignoreRules
+	}
+
+	goals := make([]pulse.Goal, 0, len(cfg.Goals))
+	for _, goal := range cfg.Goals {
+		if err := goal.Validate(); err != nil {
+			s.log.Error("Ignoring an invalid goal", "goal", goal.Name(), "err", err)
+			continue
+		}
+		goals = append(goals, goal)
+	}
+	s.goals = goals
+
+	projectRules, err := pulse.NewProjectRules(cfg.Projects.Markers, cfg.Projects.Paths)
+	if err != nil {
+		s.log.Error("Failed to compile the project rules", "err", err)
+	} else {
+		s.projectRules = projectRules
+	}
+
+	if cfg.Server.LogLevel == "" {
+		return
+	}
+	level, err := log.ParseLevel(cfg.Server.LogLevel)
+	if err != nil {
+		s.log.Error("Failed to parse the log level", "err", err)
+		return
+	}
+	s.log.SetLevel(level)
+	s.db.SetLogLevel(level)
+}
+
+// Reload applies a new configuration to the running server. The intervals of the
+// background jobs, the log level, and the segment size are updated in place.
+func (s *Server) Reload(cfg *pulse.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Info("Reloading the configuration")
+	previousHeartbeatInterval := s.heartbeatInterval
+	previousAggregationInterval := s.aggregationInterval
+	previousSegmentationInterval := s.segmentationInterval
+	s.applyConfig(cfg)
+
+	if s.heartbeatInterval != previousHeartbeatInterval {
+		notify(s.heartbeatIntervalChanged)
+	}
+	if s.aggregationInterval != previousAggregationInterval {
+		notify(s.aggregationIntervalChanged)
+	}
+	if s.segmentationInterval != previousSegmentationInterval {
+		s.db.SetSegmentationInterval(s.segmentationInterval)
+	}
+	s.db.SetSegmentSize(cmp.Or(cfg.Server.SegmentSizeKB, defaultSegmentSizeKB))
+}
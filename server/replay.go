@@ -0,0 +1,122 @@
+package server
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/clock"
+)
+
+// sessionCollector is a SessionWriter that keeps the sessions in memory.
+type sessionCollector struct {
+	mu       sync.Mutex
+	sessions pulse.CodingSessions
+}
+
+func (c *sessionCollector) Write(_ context.Context, session pulse.CodingSession) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions = append(c.sessions, session)
+	return nil
+}
+
+// dispatch calls the handler of the method that the entry was received by.
+func (s *Server) dispatch(entry JournalEntry) error {
+	reply := ""
+	switch entry.Method {
+	case methodFocusGained:
+		return s.FocusGained(entry.Event, &reply)
+	case methodOpenFile:
+		return s.OpenFile(entry.Event, &reply)
+	case methodSendHeartbeat:
+		return s.SendHeartbeat(entry.Event, &reply)
+	case methodEndSession:
+		return s.EndSession(entry.Event, &reply)
+	case methodCommit:
+		if entry.Commit == nil {
+			return nil
+		}
+		return s.Commit(*entry.Commit, &reply)
+	}
+	return fmt.Errorf("unknown method %q", entry.Method)
+}
+
+// expireIdleBuffer does what the heartbeat check would have done if the
+// buffer was left idle until the given time. Should be called with a lock.
+func (s *Server) expireIdleBuffer(mockClock *clock.MockClock, until time.Time) {
+	expiresAt := s.lastHeartbeat.Add(s.heartbeatTTL).Add(time.Nanosecond)
+	if s.activeBuffer == nil || until.Before(expiresAt) {
+		return
+	}
+	if expiresAt.After(mockClock.Now()) {
+		mockClock.Set(expiresAt)
+	}
+	if err := s.expireBuffer(); err != nil {
+		s.log.Error("Failed to expire the buffer", "err", err)
+	}
+}
+
+// Replay feeds journaled events through the same accounting code as the
+// server, using the intervals and grace periods of the configuration. The
+// sessions are recomputed under a mock clock, and returned merged by day
+// in chronological order.
+//
+// Paths are resolved against the file system as it looks today, which means
+// that files that have been moved or deleted since are skipped.
+func Replay(cfg *pulse.Config, entries []JournalEntry) (pulse.CodingSessions, error) {
+	if len(entries) == 0 {
+		return pulse.CodingSessions{}, nil
+	}
+
+	segmentPath, err := os.MkdirTemp("", "pulse-replay")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(segmentPath)
+
+	mockClock := clock.NewMock(entries[0].Time)
+	collector := &sessionCollector{}
+	s := New(cfg, segmentPath, collector, WithClock(mockClock), WithLog(log.New(io.Discard)), withoutJournalAndOutbox())
+
+	for _, entry := range entries {
+		s.mu.Lock()
+		s.expireIdleBuffer(mockClock, entry.Time)
+		s.mu.Unlock()
+
+		// Aggregate once per day, just like the server would have done at some point.
+		if s.calendar.DateString(entry.Time) != s.calendar.DateString(mockClock.Now()) {
+			s.aggregate()
+		}
+
+		// The clock can't go back in time, which could happen if the system clock was adjusted.
+		if entry.Time.After(mockClock.Now()) {
+			mockClock.Set(entry.Time)
+		}
+
+		if err = s.dispatch(entry); err != nil {
+			s.log.Error("Failed to replay the event", "method", entry.Method, "err", err)
+		}
+	}
+
+	// Let the last buffer expire, as if the editor was left idle.
+	s.mu.Lock()
+	s.expireIdleBuffer(mockClock, s.lastHeartbeat.Add(s.heartbeatTTL).Add(time.Nanosecond))
+	s.mu.Unlock()
+	s.stopLineCounts()
+	s.aggregate()
+	s.writes.Wait()
+
+	sessions := collector.sessions.MergeByDay(s.calendar)
+	slices.SortFunc(sessions, func(a, b pulse.CodingSession) int {
+		return cmp.Compare(a.EpochDateMs, b.EpochDateMs)
+	})
+	return sessions, nil
+}
@@ -1,13 +1,18 @@
 package server
 
 import (
+	"cmp"
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net"
 	"net/http"
 	"net/rpc"
+	"path/filepath"
+	"regexp"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/charmbracelet/log"
@@ -21,134 +26,357 @@ type SessionWriter interface {
 	Write(context.Context, pulse.CodingSession) error
 }
 
+// SessionReader is an abstraction for reading the daily coding sessions from a permanent storage.
+type SessionReader interface {
+	Read(ctx context.Context, epochDateMs int64) (pulse.CodingSession, error)
+	ReadRange(ctx context.Context, minDate, maxDate int64) (pulse.CodingSessions, error)
+}
+
+const (
+	// defaultIdleGracePeriod is the amount of time after the last heartbeat that
+	// we'll keep counting towards a buffer that expires due to inactivity.
+	defaultIdleGracePeriod      = 2 * time.Minute
+	defaultSegmentationInterval = 5 * time.Minute
+	defaultSegmentSizeKB        = 10
+	// shutdownTimeout is the amount of time we'll wait for pending writes to
+	// the permanent storage before they're cancelled and added to the outbox.
+	shutdownTimeout = 10 * time.Second
+)
+
 type Server struct {
-	mu            sync.Mutex
-	clock         clock.Clock
-	log           *log.Logger
-	activeBuffer  *pulse.Buffer
-	name          string
-	lastHeartbeat time.Time
-	sessionWriter SessionWriter
-	db            *pulse.LogDB
+	mu                         sync.Mutex
+	clock                      clock.Clock
+	log                        *log.Logger
+	activeBuffer               *pulse.Buffer
+	activePath                 string
+	lineJobs                   chan lineJob
+	lineJobsClosed             bool
+	lineCountsDone             chan struct{}
+	name                       string
+	network                    string
+	address                    string
+	addr                       net.Addr
+	token                      string
+	lastHeartbeat              time.Time
+	activeSince                time.Time
+	lastBreakReminder          time.Time
+	breakThreshold             time.Duration
+	breakGap                   time.Duration
+	idleGracePeriod            time.Duration
+	heartbeatTTL               time.Duration
+	heartbeatInterval          time.Duration
+	heartbeatIntervalChanged   chan struct{}
+	aggregationInterval        time.Duration
+	aggregationIntervalChanged chan struct{}
+	segmentationInterval       time.Duration
+	calendar                   pulse.Calendar
+	ticketPatterns             []*regexp.Regexp
+	ignoreRules                pulse.IgnoreRules
+	ignoreFiles                map[string]ignoreFile
+	redactor                   pulse.Redactor
+	workspaces                 []pulse.Workspace
+	projectRules               pulse.ProjectRules
+	sessionWriter              SessionWriter
+	sessionReader              SessionReader
+	outbox                     *outbox
+	quarantineDir              string
+	journalWriter              *journal
+	quarantined                atomic.Int64
+	failedWrites               atomic.Int64
+	writes                     sync.WaitGroup
+	writesCtx                  context.Context //nolint: containedctx // Lets us cancel the pending writes on shutdown.
+	cancelWrites               context.CancelFunc
+	remoteToday                pulse.Summary
+	remoteLoadedCh             chan struct{}
+	remoteLoadedOnce           sync.Once
+	aggregatedToday            pulse.Summary
+	logVersion                 uint64
+	goals                      []pulse.Goal
+	historyMu                  sync.Mutex
+	goalHistory                goalHistory
+	db                         *pulse.LogDB
 }
 
 // New creates a new server.
 func New(cfg *pulse.Config, segmentPath string, sessionWriter SessionWriter, opts ...Option) *Server {
 	s := &Server{
-		clock:         clock.New(),
-		log:           pulse.NewLogger(),
-		name:          cfg.Server.Name,
-		sessionWriter: sessionWriter,
+		clock:                      clock.New(),
+		log:                        pulse.NewLogger(),
+		name:                       cfg.Server.Name,
+		token:                      cfg.Server.Token,
+		heartbeatIntervalChanged:   make(chan struct{}, 1),
+		aggregationIntervalChanged: make(chan struct{}, 1),
+		sessionWriter:              sessionWriter,
+		outbox:                     newOutbox(filepath.Join(segmentPath, outboxDir)),
+		quarantineDir:              filepath.Join(segmentPath, quarantineDir),
+		journalWriter:              newJournal(filepath.Join(segmentPath, JournalDir)),
+		ignoreFiles:                make(map[string]ignoreFile),
+		remoteLoadedCh:             make(chan struct{}),
+		lineJobs:                   make(chan lineJob, maxQueuedLineJobs),
+		lineCountsDone:             make(chan struct{}),
 	}
 
 	for _, opt := range opts {
 		opt(s)
 	}
 
-	s.db = pulse.NewDB(segmentPath, cfg.Server.SegmentSizeKB, s.clock)
+	// There is nothing for the writes to wait for without a reader.
+	if s.sessionReader == nil {
+		s.remoteLoaded()
+	}
+
+	// The writes have a cancellation tree of their own. They should
+	// be allowed to finish after the servers context is cancelled.
+	s.writesCtx, s.cancelWrites = context.WithCancel(context.Background())
+
+	go s.runLineCounts()
+
+	s.network, s.address = cfg.Address()
+	s.db = pulse.NewDB(segmentPath, cmp.Or(cfg.Server.SegmentSizeKB, defaultSegmentSizeKB), s.clock)
+	s.applyConfig(cfg)
 
 	return s
 }
 
-func (s *Server) openFile(event pulse.Event) {
-	gitFile, gitFileErr := git.ParseFile(event.Path)
+// openFile makes the file the active buffer. The previous buffer is saved,
+// and any error that occurs while doing so is returned. Should be called with a lock.
+func (s *Server) openFile(event pulse.Event) error {
+	// Files that aren't under source control can still belong to a workspace.
+	parser := git.New()
+	parser.Projects = s.projectRules
+	gitFile, gitFileErr := parser.ParseFile(event.Path)
+	if errors.Is(gitFileErr, git.ErrReachedRoot) {
+		gitFile, gitFileErr = parser.ParseWorkspaceFile(event.Path, s.workspaces)
+	}
 	if gitFileErr != nil {
-		return
+		return nil
+	}
+
+	// Ignored files end the previous buffer, without starting a new one.
+	if s.ignores(gitFile) {
+		s.log.Debug("Ignoring the file", "path", gitFile.Path)
+		return s.saveBuffer()
 	}
 
 	if s.activeBuffer != nil {
-		if s.activeBuffer.Filepath == gitFile.Path && s.activeBuffer.Repository == gitFile.Repository {
+		if s.activeBuffer.Filepath == gitFile.Path &&
+			s.activeBuffer.Repository == gitFile.Repository &&
+			s.activeBuffer.Branch == gitFile.Branch {
 			s.log.Debug("This buffer is already considered active",
 				"path", gitFile.Path,
 				"repository", gitFile.Repository,
+				"branch", gitFile.Branch,
 				"editor_id", event.EditorID,
 				"editor", event.Editor,
 				"os", event.OS,
 			)
-			return
+			return nil
 		}
 	}
 
-	s.saveBuffer()
-	buf := pulse.NewBuffer(
-		gitFile.Name,
-		gitFile.Repository,
-		gitFile.Filetype,
-		gitFile.Path,
-		s.clock.Now(),
-	)
+	// We start counting towards the new buffer even if we failed to save the previous one.
+	err := s.saveBuffer()
+	buf := pulse.NewBuffer(gitFile, s.clock.Now())
+	buf.Tickets = pulse.ParseTickets(gitFile.Branch, s.ticketPatterns)
 	s.activeBuffer = &buf
+
+	// The snapshot of the file is taken in the background.
+	s.activePath = event.Path
+	s.queueLineJob(lineJob{buf: s.activeBuffer, path: event.Path})
+	return err
 }
 
-// saveBuffer writes the currently open buffer to disk. Should be called with a lock.
-func (s *Server) saveBuffer() {
+// saveBuffer closes the currently open buffer and writes it to disk. Should be called with a lock.
+func (s *Server) saveBuffer() error {
 	if s.activeBuffer == nil {
-		return
+		return nil
 	}
 
-	s.log.Debug("Writing the buffer")
-	buf := s.activeBuffer
-	buf.Close(s.clock.Now())
-	key := buf.Key()
+	now := s.clock.Now()
+	return s.writeBuffer(s.activeBuffer.Split(s.calendar, now, now))
+}
+
+// expireBuffer closes the currently open buffer after a period of inactivity.
+// Only the time up until the last heartbeat, plus the grace period, is counted
+// as active. Should be called with a lock.
+func (s *Server) expireBuffer() error {
+	if s.activeBuffer == nil {
+		return nil
+	}
+
+	pieces := s.activeBuffer.Split(s.calendar, s.lastHeartbeat.Add(s.idleGracePeriod), s.clock.Now())
+	return s.writeBuffer(pieces)
+}
+
+// writeBuffer writes the pieces of the closed active buffer to disk. A buffer
+// that was open at midnight has a piece for each day. The buffer is no longer
+// active afterwards, even if the write fails. Should be called with a lock.
+func (s *Server) writeBuffer(pieces pulse.Buffers) error {
+	s.log.Debug("Writing the buffer", "pieces", len(pieces))
+	defer func() {
+		s.activeBuffer = nil
+		s.activePath = ""
+	}()
+
+	// The changed lines are counted in the background. They're written as a
+	// piece of their own, without any time, that is merged with the last
+	// piece of the buffer.
+	last := pieces[len(pieces)-1]
+	piece := last
+	piece.Duration, piece.WallDuration, piece.EditDuration, piece.ReadDuration, piece.Writes = 0, 0, 0, 0, 0
+	s.queueLineJob(lineJob{buf: s.activeBuffer, path: s.activePath, closed: true, piece: piece})
+
+	var duration time.Duration
+	errs := make([]error, 0, len(pieces)+1)
+	for _, piece := range pieces {
+		duration += piece.Duration
+		errs = append(errs, s.writePiece(piece))
+	}
+	errs = append(errs, s.addUncommittedTime(last, duration))
+	return errors.Join(errs...)
+}
+
+// writePiece merges a piece of a buffer with the entry for its day. Should be called with a lock.
+func (s *Server) writePiece(piece pulse.Buffer) error {
+	buf, key := &piece, piece.Key(s.calendar)
+	s.logVersion++
 
-	// Merge the duration with the most recent entry for this day.
+	// Merge the duration with the most recent entry for this day. If
+	// the entry is corrupt, we'll move it aside and start over.
 	if bytes, hasMostRecentEntry := s.db.Get(key); hasMostRecentEntry {
 		s.log.Debug("Merging with the most recent entry for this buffer")
 		var b pulse.Buffer
-		err := json.Unmarshal(bytes, &b)
-		if err != nil {
-			panic(err)
+		if err := json.Unmarshal(bytes, &b); err != nil {
+			s.quarantine(key, bytes, err)
+		} else {
+			merged := buf.Merge(b)
+			buf = &merged
 		}
-		buf.Duration += b.Duration
 	}
 
 	bytes, err := json.Marshal(buf)
+	if err == nil {
+		err = s.db.Set(key, bytes)
+	}
 	if err != nil {
-		panic(err)
+		s.failedWrites.Add(1)
+		return fmt.Errorf("failed to write the buffer %s: %w", key, err)
 	}
-	s.db.MustSet(key, bytes)
-	s.activeBuffer = nil
+	return nil
 }
 
-// RunBackgroundJobs starts the heartbeat, aggregation, and segmentation jobs.
+// RunBackgroundJobs starts the background jobs with the given segmentation interval.
+//
+// Deprecated: The segmentation interval is read from the configuration. Use StartBackgroundJobs instead.
 func (s *Server) RunBackgroundJobs(ctx context.Context, segmentationInterval time.Duration) {
+	if segmentationInterval > 0 {
+		s.mu.Lock()
+		s.segmentationInterval = segmentationInterval
+		s.mu.Unlock()
+	}
+	s.StartBackgroundJobs(ctx)
+}
+
+// StartBackgroundJobs starts the heartbeat, aggregation, segmentation, and outbox
+// jobs. It also seeds the cached time for today from the permanent storage.
+func (s *Server) StartBackgroundJobs(ctx context.Context) {
 	go s.runHeartbeatChecks(ctx)
 	go s.runAggregations(ctx)
-	go s.db.RunSegmentations(ctx, segmentationInterval)
+	go s.db.RunSegmentations(ctx, s.interval(&s.segmentationInterval))
+	go s.loadRemote(ctx)
+	go s.runOutbox(ctx)
 }
 
-// Start starts the server on the given port.
-func (s *Server) StartServer(ctx context.Context, port string) error {
-	s.log.Info("Starting up...")
-	proxy := NewProxy(s)
-	err := rpc.RegisterName(s.name, proxy)
+// Start starts the server on the configured unix socket, or on the port of the configured hostname.
+func (s *Server) StartServer(ctx context.Context) error {
+	listener, err := listen(s.network, s.address)
 	if err != nil {
 		return err
 	}
+	return s.Serve(ctx, listener)
+}
 
-	rpc.HandleHTTP()
-	listener, err := net.Listen("tcp", ":"+port)
+// Serve accepts connections on the listener until the context is cancelled. Each
+// server has its own RPC server and mux, which allows several servers to run in
+// the same process.
+func (s *Server) Serve(ctx context.Context, listener net.Listener) error {
+	s.log.Info("Starting up...")
+	rpcServer := rpc.NewServer()
+	err := rpcServer.RegisterName(s.name, NewProxy(s))
 	if err != nil {
 		return err
 	}
+
+	mux := http.NewServeMux()
+	mux.Handle(rpc.DefaultRPCPath, s.authenticate(rpcServer))
 	httpServer := &http.Server{
+		Handler:           mux,
 		ReadHeaderTimeout: time.Second * 5,
 	}
 
+	connListener := newConnListener(listener)
+	s.mu.Lock()
+	s.addr = listener.Addr()
+	s.mu.Unlock()
+
+	serveErr := make(chan error, 1)
 	go func() {
-		serveErr := httpServer.Serve(listener)
-		if !errors.Is(serveErr, http.ErrServerClosed) {
-			panic(serveErr)
-		}
+		serveErr <- httpServer.Serve(connListener)
 	}()
 
 	// Blocks until the context is cancelled.
-	<-ctx.Done()
-	s.log.Info("Shutting down")
-	s.saveBuffer()
+	select {
+	case <-ctx.Done():
+	case err = <-serveErr:
+		return err
+	}
 
+	// Stop accepting RPCs before we flush the buffers.
+	s.log.Info("Shutting down")
 	shutdownContext, cancel := context.WithTimeout(context.Background(), time.Second*5)
 	defer cancel()
 	//nolint: contextcheck // This is a new cancellation tree.
-	return httpServer.Shutdown(shutdownContext)
+	err = httpServer.Shutdown(shutdownContext)
+	connListener.closeConns()
+	s.Shutdown(shutdownTimeout)
+	return err
+}
+
+// Shutdown closes the active buffer, runs a final aggregation, and waits for
+// the pending writes to the permanent storage. Writes that haven't finished
+// when the timeout elapses are cancelled, and added to the outbox.
+func (s *Server) Shutdown(timeout time.Duration) {
+	s.mu.Lock()
+	if err := s.saveBuffer(); err != nil {
+		s.log.Error("Failed to save the active buffer", "err", err)
+	}
+	s.mu.Unlock()
+	s.stopLineCounts()
+	s.journalWriter.close()
+	s.remoteLoaded()
+	s.aggregate()
+
+	done := make(chan struct{})
+	go func() {
+		s.writes.Wait()
+		close(done)
+	}()
+
+	deadline, stopDeadline := s.clock.NewTimer(timeout)
+	defer stopDeadline()
+	select {
+	case <-done:
+	case <-deadline:
+		s.log.Warn("Cancelling the pending writes to the permanent storage")
+		s.cancelWrites()
+		<-done
+	}
+}
+
+// Addr returns the address that the server is listening on, or
+// nil if it hasn't started to accept connections yet.
+func (s *Server) Addr() net.Addr {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.addr
 }
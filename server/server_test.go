@@ -1,18 +1,25 @@
 package server_test
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
+	"net"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"sync"
 	"testing"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/creativecreature/pulse"
+	"github.com/creativecreature/pulse/client"
 	"github.com/creativecreature/pulse/clock"
+	"github.com/creativecreature/pulse/git"
 	"github.com/creativecreature/pulse/server"
 )
 
@@ -40,6 +47,69 @@ func (m *mockStorage) GetSessions() []pulse.CodingSession {
 	return m.sessions
 }
 
+func (m *mockStorage) Read(ctx context.Context, epochDateMs int64) (pulse.CodingSession, error) {
+	sessions, err := m.ReadRange(ctx, epochDateMs, epochDateMs)
+	if err != nil || len(sessions) == 0 {
+		return pulse.CodingSession{}, err
+	}
+	return sessions[0], nil
+}
+
+func (m *mockStorage) ReadRange(_ context.Context, minDate, maxDate int64) (pulse.CodingSessions, error) {
+	m.Lock()
+	defer m.Unlock()
+	sessions := make(pulse.CodingSessions, 0)
+	for _, session := range m.sessions {
+		if session.EpochDateMs >= minDate && session.EpochDateMs <= maxDate {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions.MergeByDay(pulse.Calendar{}), nil
+}
+
+// offlineStorage is a storage that fails every write while it's offline.
+type offlineStorage struct {
+	mockStorage
+	offline bool
+}
+
+func (m *offlineStorage) Write(ctx context.Context, session pulse.CodingSession) error {
+	m.Lock()
+	offline := m.offline
+	m.Unlock()
+	if offline {
+		return errors.New("offline")
+	}
+	return m.mockStorage.Write(ctx, session)
+}
+
+func (m *offlineStorage) SetOffline(offline bool) {
+	m.Lock()
+	defer m.Unlock()
+	m.offline = offline
+}
+
+// slowStorage is a storage where every write blocks until it's cancelled.
+type slowStorage struct {
+	mockStorage
+}
+
+func (m *slowStorage) Write(ctx context.Context, _ pulse.CodingSession) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// gatedStorage is a storage where the reads block until they're released.
+type gatedStorage struct {
+	*mockStorage
+	release chan struct{}
+}
+
+func (m *gatedStorage) Read(ctx context.Context, epochDateMs int64) (pulse.CodingSession, error) {
+	<-m.release
+	return m.mockStorage.Read(ctx, epochDateMs)
+}
+
 func absolutePath(t *testing.T, relativePath string) string {
 	t.Helper()
 	_, filename, _, ok := runtime.Caller(0)
@@ -49,20 +119,26 @@ func absolutePath(t *testing.T, relativePath string) string {
 	return filepath.Join(filepath.Dir(filename), relativePath)
 }
 
-func TestServerMergesFiles(t *testing.T) {
-	t.Parallel()
-
+func TestMain(m *testing.M) {
 	// You can't commit a .git directory. Therefore, we have to rename it to .git in the test runner.
-	err := os.Rename(absolutePath(t, "./testdata/sturdyc/git"), absolutePath(t, "./testdata/sturdyc/.git"))
+	_, filename, _, _ := runtime.Caller(0)
+	testdata := filepath.Join(filepath.Dir(filename), "testdata", "sturdyc")
+	err := os.Rename(filepath.Join(testdata, "git"), filepath.Join(testdata, ".git"))
 	if err != nil {
-		t.Fatal("Failed to set up .git directory for testing:", err)
+		panic("Failed to set up .git directory for testing: " + err.Error())
 	}
-	defer func() {
-		restoreErr := os.Rename(absolutePath(t, "./testdata/sturdyc/.git"), absolutePath(t, "./testdata/sturdyc/git"))
-		if restoreErr != nil {
-			t.Fatal("Failed to store the .git directory:", restoreErr)
-		}
-	}()
+
+	code := m.Run()
+
+	restoreErr := os.Rename(filepath.Join(testdata, ".git"), filepath.Join(testdata, "git"))
+	if restoreErr != nil {
+		panic("Failed to restore the .git directory: " + restoreErr.Error())
+	}
+	os.Exit(code)
+}
+
+func TestServerMergesFiles(t *testing.T) {
+	t.Parallel()
 
 	mockClock := clock.NewMock(time.Now())
 	mockStorage := newMockStorage()
@@ -81,7 +157,7 @@ func TestServerMergesFiles(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go func() {
-		s.RunBackgroundJobs(ctx, cfg.Server.SegmentationInterval)
+		s.StartBackgroundJobs(ctx)
 	}()
 	time.Sleep(100 * time.Millisecond)
 
@@ -159,3 +235,1487 @@ func TestServerMergesFiles(t *testing.T) {
 		t.Errorf("expected the repositories files to be 2; got %d", len(storedSessions[0].Repositories[0].Files))
 	}
 }
+
+func TestServerExpiresIdleBuffers(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+	cfg.Server.IdleGracePeriod = time.Minute
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+
+	// Walk away from the editor without ending the session. The heartbeat
+	// check should expire the buffer, and only count the grace period.
+	mockClock.Add(11 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].TotalTimeMs != time.Minute.Milliseconds() {
+		t.Errorf("expected the sessions duration to be %d; got %d", time.Minute.Milliseconds(), storedSessions[0].TotalTimeMs)
+	}
+}
+
+func TestServerReloadsAggregationInterval(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(100 * time.Millisecond)
+	s.EndSession(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+
+	reloadedCfg := cfg
+	reloadedCfg.Server.AggregationInterval = time.Minute
+	s.Reload(&reloadedCfg)
+	time.Sleep(100 * time.Millisecond)
+
+	// The original interval of 10 minutes hasn't passed, but the reloaded one has.
+	mockClock.Add(2 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].TotalTimeMs != 100 {
+		t.Errorf("expected the sessions duration to be 100; got %d", storedSessions[0].TotalTimeMs)
+	}
+}
+
+func TestServerSplitsEditingAndReading(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	event := pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}
+	s.OpenFile(event, &reply)
+
+	// Type for 10 seconds. Each change counts the following 30 seconds as editing.
+	event.Type = pulse.TextChanged
+	s.SendHeartbeat(event, &reply)
+	mockClock.Add(10 * time.Second)
+	s.SendHeartbeat(event, &reply)
+
+	event.Type = pulse.Write
+	s.SendHeartbeat(event, &reply)
+
+	// Then read the file for another minute. The changes and writes that are
+	// made to another file, e.g. in a second editor, aren't credited to it.
+	mockClock.Add(30 * time.Second)
+	event.Type = pulse.CursorMoved
+	s.SendHeartbeat(event, &reply)
+	other := event
+	other.Path = absolutePath(t, "/testdata/sturdyc/pkg/foo/foo.go")
+	for _, eventType := range []pulse.EventType{pulse.TextChanged, pulse.Write} {
+		other.Type = eventType
+		s.SendHeartbeat(other, &reply)
+	}
+	mockClock.Add(30 * time.Second)
+	s.EndSession(event, &reply)
+
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	session := storedSessions[0]
+	if session.TotalTimeMs != 70_000 {
+		t.Errorf("expected the sessions duration to be 70000; got %d", session.TotalTimeMs)
+	}
+	if session.EditingTimeMs != 40_000 {
+		t.Errorf("expected the editing time to be 40000; got %d", session.EditingTimeMs)
+	}
+	if session.ReadingTimeMs != 30_000 {
+		t.Errorf("expected the reading time to be 30000; got %d", session.ReadingTimeMs)
+	}
+	if session.Writes != 1 {
+		t.Errorf("expected the number of writes to be 1; got %d", session.Writes)
+	}
+	file := session.Repositories[0].Files[0]
+	if file.EditingMs != 40_000 || file.ReadingMs != 30_000 || file.Writes != 1 {
+		t.Errorf("expected the file to be edited for 40000ms, read for 30000ms, and written once; got %+v", file)
+	}
+}
+
+// createRepository creates a git repository in a temporary directory.
+func createRepository(t *testing.T, name string) string {
+	t.Helper()
+	dir := t.TempDir()
+	err := os.Mkdir(filepath.Join(dir, ".git"), 0o755)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := "[remote \"origin\"]\n\turl = git@github.com:creativecreature/" + name + ".git\n"
+	err = os.WriteFile(filepath.Join(dir, ".git", "config"), []byte(config), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkout(t, dir, "main")
+	return dir
+}
+
+// checkout points the HEAD of the repository to the given branch.
+func checkout(t *testing.T, repositoryPath, branch string) {
+	t.Helper()
+	head := "ref: refs/heads/" + branch + "\n"
+	err := os.WriteFile(filepath.Join(repositoryPath, ".git", "HEAD"), []byte(head), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestServerCountsChangedLines(t *testing.T) {
+	t.Parallel()
+
+	repositoryPath := createRepository(t, "lines")
+	filePath := filepath.Join(repositoryPath, "main.go")
+	err := os.WriteFile(filePath, []byte("package main\n\nfunc main() {\n}\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	event := pulse.Event{EditorID: "123", Path: filePath, Editor: "nvim", OS: "Linux"}
+	s.OpenFile(event, &reply)
+	mockClock.Add(time.Minute)
+	// The snapshot of the file is taken in the background.
+	time.Sleep(100 * time.Millisecond)
+
+	// Add two lines to the body of the function, and remove the blank line.
+	err = os.WriteFile(filePath, []byte("package main\nfunc main() {\n\tfoo()\n\tbar()\n}\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EndSession(event, &reply)
+	time.Sleep(100 * time.Millisecond)
+
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	file := storedSessions[0].Repositories[0].Files[0]
+	if file.LinesAdded != 2 {
+		t.Errorf("expected 2 lines to have been added; got %d", file.LinesAdded)
+	}
+	if file.LinesRemoved != 1 {
+		t.Errorf("expected 1 line to have been removed; got %d", file.LinesRemoved)
+	}
+	if storedSessions[0].LinesAdded != 2 || storedSessions[0].LinesRemoved != 1 {
+		t.Errorf("expected the session to have +2 -1 lines; got +%d -%d",
+			storedSessions[0].LinesAdded, storedSessions[0].LinesRemoved)
+	}
+}
+
+func TestServerSkipsLinesOfLargeFiles(t *testing.T) {
+	t.Parallel()
+
+	repositoryPath := createRepository(t, "large")
+	filePath := filepath.Join(repositoryPath, "generated.go")
+	large := bytes.Repeat([]byte("var _ = \"a generated line\"\n"), 60_000)
+	err := os.WriteFile(filePath, large, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	event := pulse.Event{EditorID: "123", Path: filePath, Editor: "nvim", OS: "Linux"}
+	s.OpenFile(event, &reply)
+	mockClock.Add(time.Minute)
+
+	// The time is tracked, but the file is too large to be diffed.
+	err = os.WriteFile(filePath, append(large, "another line\n"...), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.EndSession(event, &reply)
+
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].TotalTimeMs != time.Minute.Milliseconds() {
+		t.Errorf("expected the sessions duration to be %d; got %d", time.Minute.Milliseconds(), storedSessions[0].TotalTimeMs)
+	}
+	if storedSessions[0].LinesAdded != 0 || storedSessions[0].LinesRemoved != 0 {
+		t.Errorf("expected the lines of the large file to not be counted; got +%d -%d",
+			storedSessions[0].LinesAdded, storedSessions[0].LinesRemoved)
+	}
+}
+
+func TestServerTracksBranches(t *testing.T) {
+	t.Parallel()
+
+	repositoryPath := createRepository(t, "branches")
+	filePath := filepath.Join(repositoryPath, "main.go")
+	err := os.WriteFile(filePath, []byte("package main\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	event := pulse.Event{EditorID: "123", Path: filePath, Editor: "nvim", OS: "Linux"}
+	s.OpenFile(event, &reply)
+	mockClock.Add(time.Minute)
+
+	// Switching branch should close the buffer, even though the path is the same.
+	checkout(t, repositoryPath, "feat/PROJ-1234-some-title")
+	s.OpenFile(event, &reply)
+	mockClock.Add(2 * time.Minute)
+	s.EndSession(event, &reply)
+
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	repository := storedSessions[0].Repositories[0]
+	if len(repository.Files) != 1 {
+		t.Errorf("expected the repositories files to be 1; got %d", len(repository.Files))
+	}
+
+	durations := make(map[string]int64)
+	for _, branch := range repository.Branches {
+		durations[branch.Name] = branch.DurationMs
+	}
+	if durations["main"] != time.Minute.Milliseconds() {
+		t.Errorf("expected main to have been worked on for %d; got %d", time.Minute.Milliseconds(), durations["main"])
+	}
+	if durations["feat/PROJ-1234-some-title"] != 2*time.Minute.Milliseconds() {
+		t.Errorf("expected the feature branch to have been worked on for %d; got %d",
+			2*time.Minute.Milliseconds(), durations["feat/PROJ-1234-some-title"])
+	}
+}
+
+func TestServerAttributesTimeToCommits(t *testing.T) {
+	t.Parallel()
+
+	repositoryPath := createRepository(t, "commits")
+	mainPath := filepath.Join(repositoryPath, "main.go")
+	readmePath := filepath.Join(repositoryPath, "README.md")
+	for _, path := range []string{mainPath, readmePath} {
+		err := os.WriteFile(path, []byte("package main\n"), 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// The time is only tracked for repositories that have sent a commit.
+	commit := pulse.Commit{
+		SHA:         "d670460b4b4aece5915caf5c68d12f560a9fe3e4",
+		Repository:  "commits",
+		Branch:      "main",
+		CommittedAt: mockClock.Now(),
+		Files:       []string{"commits/README.md"},
+	}
+	s.Commit(commit, &reply)
+
+	s.OpenFile(pulse.Event{EditorID: "123", Path: readmePath, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: mainPath, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(2 * time.Minute)
+
+	// The commit only includes main.go, which is still open.
+	commit.SHA = "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	commit.CommittedAt = mockClock.Now()
+	commit.Files = []string{"commits/main.go"}
+	s.Commit(commit, &reply)
+
+	// Time spent after the commit is attributed to the next one.
+	mockClock.Add(time.Minute)
+	s.EndSession(pulse.Event{EditorID: "123", Path: mainPath, Editor: "nvim", OS: "Linux"}, &reply)
+	commit.SHA = "8a5ba44ab4e6f0e7a8d4b2ab0b0ee44ebff0c5ec"
+	commit.CommittedAt = mockClock.Now()
+	s.Commit(commit, &reply)
+
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	repository := storedSessions[0].Repositories[0]
+	if repository.DurationMs != 4*time.Minute.Milliseconds() {
+		t.Errorf("expected the repositories duration to be %d; got %d", 4*time.Minute.Milliseconds(), repository.DurationMs)
+	}
+	if len(repository.Commits) != 3 {
+		t.Fatalf("expected 3 commits; got %d", len(repository.Commits))
+	}
+	durations := make(map[string]int64)
+	for _, c := range repository.Commits {
+		durations[c.SHA] = c.DurationMs
+	}
+	if durations["d670460b4b4aece5915caf5c68d12f560a9fe3e4"] != 0 {
+		t.Errorf("expected the first commit of the hook not to be attributed any time; got %d",
+			durations["d670460b4b4aece5915caf5c68d12f560a9fe3e4"])
+	}
+	if durations["4b825dc642cb6eb9a060e54bf8d69288fbee4904"] != 2*time.Minute.Milliseconds() {
+		t.Errorf("expected the second commit to be attributed %d; got %d",
+			2*time.Minute.Milliseconds(), durations["4b825dc642cb6eb9a060e54bf8d69288fbee4904"])
+	}
+	if durations["8a5ba44ab4e6f0e7a8d4b2ab0b0ee44ebff0c5ec"] != time.Minute.Milliseconds() {
+		t.Errorf("expected the third commit to be attributed %d; got %d",
+			time.Minute.Milliseconds(), durations["8a5ba44ab4e6f0e7a8d4b2ab0b0ee44ebff0c5ec"])
+	}
+}
+
+func TestServerAttributesTimeToCommitsInRenamedRepositories(t *testing.T) {
+	t.Parallel()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	runGit := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=pulse", "GIT_AUTHOR_EMAIL=pulse@example.com",
+			"GIT_COMMITTER_NAME=pulse", "GIT_COMMITTER_EMAIL=pulse@example.com",
+		)
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, output)
+		}
+	}
+
+	// The repository is a fork, which is tracked under the name of the original.
+	runGit("init", "--initial-branch=main")
+	runGit("remote", "add", "origin", "git@github.com:conner/pulse-fork.git")
+	files := map[string]string{
+		pulse.RepositoryConfigFilename: "name: pulse\n",
+		"main.go":                      "package main\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mockClock := clock.NewMock(time.Now())
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// The time is only tracked for repositories that have sent a commit.
+	commitFile := func(name string) {
+		t.Helper()
+		runGit("add", name)
+		runGit("commit", "-m", "Add "+name)
+		commit, err := git.ParseCommit(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Commit(commit, &reply)
+	}
+	commitFile(pulse.RepositoryConfigFilename)
+
+	event := pulse.Event{EditorID: "123", Path: filepath.Join(dir, "main.go"), Editor: "nvim", OS: "Linux"}
+	s.OpenFile(event, &reply)
+	mockClock.Add(2 * time.Minute)
+	commitFile("main.go")
+	s.EndSession(event, &reply)
+
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	repository := storedSessions[0].Repositories[0]
+	if repository.Name != "pulse" {
+		t.Errorf("expected the repository to be pulse; got %s", repository.Name)
+	}
+	if len(repository.Commits) != 2 {
+		t.Fatalf("expected 2 commits; got %d", len(repository.Commits))
+	}
+	for _, commit := range repository.Commits {
+		expected := int64(0)
+		if slices.Contains(commit.Files, "pulse/main.go") {
+			expected = 2 * time.Minute.Milliseconds()
+		}
+		if commit.DurationMs != expected {
+			t.Errorf("expected the commit of %v to be attributed %d; got %d", commit.Files, expected, commit.DurationMs)
+		}
+	}
+}
+
+func TestServerForgetsTheUncommittedTimeOfStaleFiles(t *testing.T) {
+	t.Parallel()
+
+	hooked := createRepository(t, "hooked")
+	unhooked := createRepository(t, "unhooked")
+	workspace := t.TempDir()
+	paths := []string{
+		filepath.Join(hooked, "main.go"),
+		filepath.Join(unhooked, "main.go"),
+		filepath.Join(workspace, "main.go"),
+	}
+	for _, path := range paths {
+		if err := os.WriteFile(path, []byte("package main\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Workspaces = []pulse.Workspace{{Name: "notes", Path: workspace}}
+
+	segmentPath := t.TempDir()
+	s := server.New(&cfg, segmentPath, newMockStorage(),
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// The workspace can't be committed, even if a commit claims otherwise.
+	reply := ""
+	for _, repository := range []string{"hooked", "notes"} {
+		s.Commit(pulse.Commit{SHA: repository, Repository: repository, CommittedAt: mockClock.Now()}, &reply)
+	}
+	for _, path := range paths {
+		s.OpenFile(pulse.Event{EditorID: "123", Path: path, Editor: "nvim", OS: "Linux"}, &reply)
+		mockClock.Add(time.Minute)
+	}
+	s.EndSession(pulse.Event{EditorID: "123"}, &reply)
+
+	countKeys := func(prefix string) int {
+		t.Helper()
+		return len(pulse.NewDB(segmentPath, 10, mockClock).GetByPrefix(prefix))
+	}
+
+	// Only the file in the repository with a hook is tracked, and it survives the aggregation.
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+	if count := countKeys("uncommitted_"); count != 1 {
+		t.Errorf("expected the uncommitted time of %d file; got %d", 1, count)
+	}
+
+	// Files that aren't worked on, and hooks that don't send any commits, are eventually dropped.
+	for range 31 {
+		mockClock.Add(24 * time.Hour)
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.Shutdown(time.Minute)
+	for _, prefix := range []string{"uncommitted_", "hook_"} {
+		if count := countKeys(prefix); count != 0 {
+			t.Errorf("expected the %s keys to have been dropped; got %d", prefix, count)
+		}
+	}
+}
+
+func TestServerSummarizesToday(t *testing.T) {
+	t.Parallel()
+
+	// Use a fixed time to make sure that the test doesn't cross midnight.
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	mainFile := absolutePath(t, "/testdata/sturdyc/cmd/main.go")
+	fooFile := absolutePath(t, "/testdata/sturdyc/pkg/foo/foo.go")
+
+	// The first buffer is going to be aggregated and written to the remote.
+	s.OpenFile(pulse.Event{EditorID: "123", Path: mainFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: fooFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(9 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	// The second buffer is written to the log, and the third remains active.
+	s.OpenFile(pulse.Event{EditorID: "123", Path: mainFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(30 * time.Second)
+
+	if len(mockStorage.GetSessions()) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(mockStorage.GetSessions()))
+	}
+
+	var summary pulse.Summary
+	s.Today(&summary)
+
+	expectedMs := (10*time.Minute + 30*time.Second).Milliseconds()
+	if summary.DateString != "2024-01-01" {
+		t.Errorf("expected the date to be %s; got %s", "2024-01-01", summary.DateString)
+	}
+	if summary.TotalTimeMs != expectedMs {
+		t.Errorf("expected the total time to be %d; got %d", expectedMs, summary.TotalTimeMs)
+	}
+	if summary.Repositories["sturdyc"] != expectedMs {
+		t.Errorf("expected the repository time to be %d; got %d", expectedMs, summary.Repositories["sturdyc"])
+	}
+	if summary.Filetypes["go"] != expectedMs {
+		t.Errorf("expected the filetype time to be %d; got %d", expectedMs, summary.Filetypes["go"])
+	}
+}
+
+func TestServerSplitsBuffersAtMidnight(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 23, 52, 0, 0, time.Local))
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	// Keep the buffer open from 23:52 until 00:06 the next day.
+	event := pulse.Event{EditorID: "123", Path: absolutePath(t, "/testdata/sturdyc/cmd/main.go"), Editor: "nvim", OS: "Linux"}
+	s.OpenFile(event, &reply)
+	for i := 0; i < 3; i++ {
+		mockClock.Add(4 * time.Minute)
+		s.SendHeartbeat(event, &reply)
+	}
+	mockClock.Add(2 * time.Minute)
+
+	// Only the time after midnight counts towards today.
+	var summary pulse.Summary
+	s.Today(&summary)
+	if summary.DateString != "2024-01-02" || summary.TotalTimeMs != (6*time.Minute).Milliseconds() {
+		t.Errorf("expected %d ms on %s; got %d ms on %s", (6 * time.Minute).Milliseconds(), "2024-01-02", summary.TotalTimeMs, summary.DateString)
+	}
+
+	s.EndSession(event, &reply)
+	mockClock.Add(10 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	totals := make(map[string]int64)
+	for _, session := range mockStorage.GetSessions() {
+		totals[session.DateString] += session.TotalTimeMs
+	}
+	expected := map[string]int64{
+		"2024-01-01": (8 * time.Minute).Milliseconds(),
+		"2024-01-02": (6 * time.Minute).Milliseconds(),
+	}
+	for date, ms := range expected {
+		if totals[date] != ms {
+			t.Errorf("expected %d ms on %s; got %d", ms, date, totals[date])
+		}
+	}
+}
+
+func TestServerDoesNotCountTodayTwice(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	storage := &gatedStorage{mockStorage: newMockStorage(), release: make(chan struct{})}
+
+	// Five minutes were written to the permanent storage before the server was started.
+	earlier := pulse.Buffer{
+		OpenedAt:   mockClock.Now().Add(-time.Hour),
+		Filepath:   "sturdyc/cmd/main.go",
+		Filetype:   "go",
+		Repository: "sturdyc",
+		Duration:   5 * time.Minute,
+	}
+	storage.Write(context.Background(), pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{earlier}, nil, mockClock.Now())[0])
+
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), storage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+		server.WithSessionReader(storage),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	event := pulse.Event{EditorID: "123", Path: absolutePath(t, "/testdata/sturdyc/cmd/main.go"), Editor: "nvim", OS: "Linux"}
+	s.OpenFile(event, &reply)
+	mockClock.Add(time.Minute)
+	s.EndSession(event, &reply)
+	mockClock.Add(9 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	// The minute has been aggregated, and is counted even though the write
+	// is held back until the time of today has been read from the storage.
+	var summary pulse.Summary
+	s.Today(&summary)
+	if summary.TotalTimeMs != time.Minute.Milliseconds() {
+		t.Errorf("expected the total time to be %d; got %d", time.Minute.Milliseconds(), summary.TotalTimeMs)
+	}
+	if len(storage.GetSessions()) != 1 {
+		t.Fatalf("expected the write to be held back; got %d sessions", len(storage.GetSessions()))
+	}
+
+	close(storage.release)
+	time.Sleep(200 * time.Millisecond)
+
+	expectedMs := (6 * time.Minute).Milliseconds()
+	s.Today(&summary)
+	if summary.TotalTimeMs != expectedMs {
+		t.Errorf("expected the total time to be %d; got %d", expectedMs, summary.TotalTimeMs)
+	}
+	if len(storage.GetSessions()) != 2 {
+		t.Errorf("expected sessions %d; got %d", 2, len(storage.GetSessions()))
+	}
+}
+
+func TestServerEvaluatesGoals(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	mockClock := clock.NewMock(now)
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+	cfg.Server.SegmentationInterval = 5 * time.Minute
+	cfg.Server.SegmentSizeKB = 10
+	cfg.Goals = []pulse.Goal{
+		{Period: pulse.GoalDaily, Target: time.Minute},
+		{Period: pulse.GoalDaily, Target: time.Hour, Filetype: "go"},
+	}
+
+	// The two previous days have been written to the permanent storage.
+	for _, day := range []time.Time{now.AddDate(0, 0, -2), now.AddDate(0, 0, -1)} {
+		buffers := pulse.Buffers{{Repository: "sturdyc", Filepath: "sturdyc/main.go", Filetype: "go", Duration: 30 * time.Minute}}
+		//nolint: errcheck // The mock storage never fails.
+		mockStorage.Write(context.Background(), pulse.NewCodingSessions(pulse.Calendar{}, buffers, pulse.Commits{}, day)[0])
+	}
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+		server.WithSessionReader(mockStorage),
+	)
+
+	mainFile := absolutePath(t, "/testdata/sturdyc/cmd/main.go")
+	s.OpenFile(pulse.Event{EditorID: "123", Path: mainFile, Editor: "nvim", OS: "Linux"}, &reply)
+	mockClock.Add(90 * time.Second)
+
+	goals, err := s.Goals(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(goals) != 2 {
+		t.Fatalf("expected 2 goals, got %d", len(goals))
+	}
+
+	// The daily goal has been met today, and on the two previous days.
+	if !goals[0].Met || goals[0].Streak != 3 {
+		t.Errorf("expected the daily goal to be met with a streak of 3, got %+v", goals[0])
+	}
+	if goals[0].ProgressMs != (90 * time.Second).Milliseconds() {
+		t.Errorf("expected the progress to be %d, got %d", (90 * time.Second).Milliseconds(), goals[0].ProgressMs)
+	}
+
+	// The goal for go has never been met.
+	if goals[1].Met || goals[1].Streak != 0 {
+		t.Errorf("expected the go goal to be unmet without a streak, got %+v", goals[1])
+	}
+}
+
+func TestServerRemindsToTakeBreaks(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.SegmentSizeKB = 10
+	cfg.Breaks.Threshold = 50 * time.Minute
+	cfg.Breaks.Gap = 5 * time.Minute
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), newMockStorage(),
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+	event := pulse.Event{EditorID: "123", Path: absolutePath(t, "/testdata/sturdyc/cmd/main.go"), Editor: "nvim", OS: "Linux"}
+
+	// activeFor sends a heartbeat every four minutes, which is short enough to not count as a break.
+	activeFor := func(d time.Duration) {
+		for end := mockClock.Now().Add(d); mockClock.Now().Before(end); {
+			mockClock.Add(4 * time.Minute)
+			s.SendHeartbeat(event, &reply)
+		}
+	}
+
+	s.OpenFile(event, &reply)
+	activeFor(48 * time.Minute)
+	if active := s.Break(); active != 0 {
+		t.Fatalf("expected no reminder before the threshold, got %s", active)
+	}
+
+	activeFor(4 * time.Minute)
+	if active := s.Break(); active != 52*time.Minute {
+		t.Fatalf("expected a reminder after %s, got %s", 52*time.Minute, active)
+	}
+	if active := s.Break(); active != 0 {
+		t.Errorf("expected the reminder to be given once, got %s", active)
+	}
+
+	// The reminder is repeated once another threshold has elapsed.
+	activeFor(48 * time.Minute)
+	if active := s.Break(); active != 0 {
+		t.Errorf("expected no reminder before another threshold, got %s", active)
+	}
+	activeFor(4 * time.Minute)
+	if active := s.Break(); active != 104*time.Minute {
+		t.Errorf("expected a reminder after %s, got %s", 104*time.Minute, active)
+	}
+
+	// A gap that is long enough counts as a break, and starts a new stretch.
+	mockClock.Add(6 * time.Minute)
+	if active := s.Break(); active != 0 {
+		t.Errorf("expected no reminder during a break, got %s", active)
+	}
+	s.SendHeartbeat(event, &reply)
+	activeFor(48 * time.Minute)
+	if active := s.Break(); active != 0 {
+		t.Errorf("expected no reminder after the break, got %s", active)
+	}
+}
+
+// serve starts the server on a random port, and returns its address once it's
+// accepting connections. The test waits for the server to shut down when it ends.
+func serve(ctx context.Context, t *testing.T, s *server.Server) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan struct{})
+	go func() {
+		//nolint: errcheck // The server is shut down when the test ends.
+		s.Serve(ctx, listener)
+		close(done)
+	}()
+	t.Cleanup(func() { <-done })
+
+	for s.Addr() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	return s.Addr().String()
+}
+
+func TestServerRejectsInvalidTokens(t *testing.T) {
+	t.Parallel()
+
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.Token = "secret"
+
+	s := server.New(&cfg, t.TempDir(), newMockStorage(), server.WithLog(log.New(io.Discard)))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	network, address := "tcp", serve(ctx, t, s)
+	_, err := client.New(cfg.Server.Name, network, address, "")
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected a missing token to be rejected; got %v", err)
+	}
+
+	_, err = client.New(cfg.Server.Name, network, address, "wrong")
+	if !errors.Is(err, client.ErrUnauthorized) {
+		t.Errorf("expected an invalid token to be rejected; got %v", err)
+	}
+
+	c, err := client.New(cfg.Server.Name, network, address, cfg.Server.Token)
+	if err != nil {
+		t.Fatalf("expected the token to be accepted; got %v", err)
+	}
+	today, _ := c.Today(nil)
+	if today != "0m" {
+		t.Errorf("expected the call to return %s; got %q", "0m", today)
+	}
+}
+
+func TestServersRunIndependently(t *testing.T) {
+	t.Parallel()
+
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+
+	ctxA, cancelA := context.WithCancel(context.Background())
+	defer cancelA()
+	ctxB, cancelB := context.WithCancel(context.Background())
+	defer cancelB()
+
+	// Both servers are registered with the same name in the same process.
+	a := server.New(&cfg, t.TempDir(), newMockStorage(), server.WithLog(log.New(io.Discard)))
+	b := server.New(&cfg, t.TempDir(), newMockStorage(), server.WithLog(log.New(io.Discard)))
+	addressA, addressB := serve(ctxA, t, a), serve(ctxB, t, b)
+	if addressA == addressB {
+		t.Fatalf("expected the servers to listen on different addresses; got %s", addressA)
+	}
+
+	clientA, err := client.New(cfg.Server.Name, "tcp", addressA, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientB, err := client.New(cfg.Server.Name, "tcp", addressB, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Stopping the first server should close its connections, but leave the second one running.
+	cancelA()
+	time.Sleep(100 * time.Millisecond)
+	if err = clientA.Commit(pulse.Commit{}); err == nil {
+		t.Error("expected the call to the stopped server to fail")
+	}
+	if err = clientB.Commit(pulse.Commit{}); err != nil {
+		t.Errorf("expected the call to the running server to succeed; got %v", err)
+	}
+}
+
+func TestServerRetriesFailedWrites(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	storage := &offlineStorage{offline: true}
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+
+	reply := ""
+	segmentPath := t.TempDir()
+	s := server.New(&cfg, segmentPath, storage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(time.Minute)
+	s.EndSession(pulse.Event{EditorID: "123"}, &reply)
+
+	// The aggregation fails to write the session, which should leave it in the outbox.
+	mockClock.Add(9 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+	if s.OutboxSize() != 1 {
+		t.Fatalf("expected the outbox to contain %d sessions; got %d", 1, s.OutboxSize())
+	}
+
+	// The outbox should survive a restart of the server.
+	restarted := server.New(&cfg, segmentPath, storage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+	if restarted.OutboxSize() != 1 {
+		t.Fatalf("expected the restarted outbox to contain %d sessions; got %d", 1, restarted.OutboxSize())
+	}
+
+	// The session should be written once we come back online and the backoff has elapsed.
+	storage.SetOffline(false)
+	mockClock.Add(2 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	if s.OutboxSize() != 0 {
+		t.Errorf("expected the outbox to be empty; got %d sessions", s.OutboxSize())
+	}
+	storedSessions := storage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].TotalTimeMs != time.Minute.Milliseconds() {
+		t.Errorf("expected the sessions duration to be %d; got %d", time.Minute.Milliseconds(), storedSessions[0].TotalTimeMs)
+	}
+}
+
+func TestServerAggregatesOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(time.Minute)
+
+	// The active buffer should be closed, aggregated, and written before we return.
+	s.Shutdown(time.Minute)
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].TotalTimeMs != time.Minute.Milliseconds() {
+		t.Errorf("expected the sessions duration to be %d; got %d", time.Minute.Milliseconds(), storedSessions[0].TotalTimeMs)
+	}
+}
+
+func TestServerPersistsPendingWritesOnShutdown(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), &slowStorage{},
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- s.Serve(ctx, listener)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	c, err := client.New(cfg.Server.Name, "tcp", listener.Addr().String(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	mockClock.Add(time.Minute)
+
+	// Once the context is cancelled, the server should stop accepting
+	// RPCs, and wait for the write that is blocked by the slow storage.
+	cancel()
+	time.Sleep(100 * time.Millisecond)
+	if err = c.Commit(pulse.Commit{}); err == nil {
+		t.Error("expected the server to stop accepting RPCs")
+	}
+	select {
+	case <-serveErr:
+		t.Fatal("expected the server to wait for the pending write")
+	default:
+	}
+
+	// When the deadline elapses, the write should be cancelled and added to the outbox.
+	mockClock.Add(time.Minute)
+	select {
+	case err = <-serveErr:
+		if err != nil {
+			t.Errorf("expected the server to shut down without errors; got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the server to shut down")
+	}
+	if s.OutboxSize() != 1 {
+		t.Errorf("expected the outbox to contain %d sessions; got %d", 1, s.OutboxSize())
+	}
+}
+
+func TestServerQuarantinesCorruptValues(t *testing.T) {
+	t.Parallel()
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.AggregationInterval = 10 * time.Minute
+
+	// Leave a value that can't be decoded in the log.
+	segmentPath := t.TempDir()
+	pulse.NewDB(segmentPath, 10, mockClock).MustSet("2024-01-01_sturdyc_main_sturdyc/corrupt.go", []byte("{"))
+
+	reply := ""
+	s := server.New(&cfg, segmentPath, mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		s.StartBackgroundJobs(ctx)
+	}()
+	time.Sleep(100 * time.Millisecond)
+
+	err := s.OpenFile(pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}, &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mockClock.Add(time.Minute)
+	err = s.EndSession(pulse.Event{EditorID: "123"}, &reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The aggregation should quarantine the corrupt value, and write the rest.
+	mockClock.Add(9 * time.Minute)
+	time.Sleep(200 * time.Millisecond)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	if storedSessions[0].TotalTimeMs != time.Minute.Milliseconds() {
+		t.Errorf("expected the sessions duration to be %d; got %d", time.Minute.Milliseconds(), storedSessions[0].TotalTimeMs)
+	}
+
+	if counts := s.ErrorCounts(); counts.Quarantined != 1 {
+		t.Errorf("expected %d quarantined values; got %d", 1, counts.Quarantined)
+	}
+	entries, err := os.ReadDir(filepath.Join(segmentPath, "quarantine"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected %d files in the quarantine; got %d", 1, len(entries))
+	}
+}
+
+func TestServerReplaysTheJournal(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local)
+	mockClock := clock.NewMock(start)
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.IdleGracePeriod = 2 * time.Minute
+
+	segmentPath := t.TempDir()
+	journalDir := filepath.Join(segmentPath, server.JournalDir)
+	if err := os.MkdirAll(journalDir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	// The journal is kept for 30 days by default.
+	for _, date := range []string{"2023-12-01", "2023-12-02"} {
+		if err := os.WriteFile(filepath.Join(journalDir, date+".jsonl"), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := server.New(&cfg, segmentPath, newMockStorage(),
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+	proxy := server.NewProxy(s)
+
+	// Work for three minutes, and then leave the editor open for half an hour.
+	reply := ""
+	event := pulse.Event{
+		EditorID: "123",
+		Path:     absolutePath(t, "/testdata/sturdyc/cmd/main.go"),
+		Editor:   "nvim",
+		OS:       "Linux",
+	}
+	proxy.OpenFile(event, &reply)
+	mockClock.Add(3 * time.Minute)
+	event.Type = pulse.TextChanged
+	proxy.SendHeartbeat(event, &reply)
+	mockClock.Add(30 * time.Minute)
+	proxy.EndSession(pulse.Event{EditorID: "123"}, &reply)
+	s.Shutdown(time.Minute)
+
+	if _, err := os.Stat(filepath.Join(journalDir, "2023-12-01.jsonl")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the journal of 2023-12-01 to be pruned; got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(journalDir, "2023-12-02.jsonl")); err != nil {
+		t.Errorf("expected the journal of 2023-12-02 to be kept; got %v", err)
+	}
+
+	entries, err := server.ReadJournal(pulse.Calendar{}, journalDir, start, start)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected %d journal entries; got %d", 3, len(entries))
+	}
+	if !entries[1].Time.Equal(start.Add(3*time.Minute)) || entries[1].Event.Type != pulse.TextChanged {
+		t.Errorf("expected the heartbeat to be journaled with its time and type; got %+v", entries[1])
+	}
+
+	// The idle time after the last heartbeat depends on the grace period.
+	for _, gracePeriod := range []time.Duration{2 * time.Minute, 5 * time.Minute} {
+		replayCfg := cfg
+		replayCfg.Server.IdleGracePeriod = gracePeriod
+		sessions, replayErr := server.Replay(&replayCfg, entries)
+		if replayErr != nil {
+			t.Fatal(replayErr)
+		}
+		if len(sessions) != 1 {
+			t.Fatalf("expected sessions %d; got %d", 1, len(sessions))
+		}
+		replayed, _ := server.ReadJournal(pulse.Calendar{}, journalDir, start, start)
+		if len(replayed) != len(entries) {
+			t.Errorf("expected the replay not to journal any events; got %d entries", len(replayed))
+		}
+		expected := (3*time.Minute + gracePeriod).Milliseconds()
+		if sessions[0].TotalTimeMs != expected {
+			t.Errorf("expected the replayed duration to be %d; got %d", expected, sessions[0].TotalTimeMs)
+		}
+	}
+}
+
+func TestServerIgnoresFiles(t *testing.T) {
+	t.Parallel()
+
+	repositoryPath := createRepository(t, "ignored")
+	for _, name := range []string{"main.go", "vendor/lib.go", "scratch.go"} {
+		path := filepath.Join(repositoryPath, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("package main\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := os.WriteFile(filepath.Join(repositoryPath, ".pulseignore"), []byte("scratch.go\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mockClock := clock.NewMock(time.Date(2024, 1, 1, 12, 0, 0, 0, time.Local))
+	mockStorage := newMockStorage()
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Ignore.Paths = []string{"vendor/"}
+
+	reply := ""
+	s := server.New(&cfg, t.TempDir(), mockStorage,
+		server.WithLog(log.New(io.Discard)),
+		server.WithClock(mockClock),
+	)
+
+	// Time spent in ignored files shouldn't be counted towards the previous buffer.
+	s.OpenFile(pulse.Event{EditorID: "123", Path: filepath.Join(repositoryPath, "main.go")}, &reply)
+	mockClock.Add(time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: filepath.Join(repositoryPath, "vendor/lib.go")}, &reply)
+	mockClock.Add(5 * time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: filepath.Join(repositoryPath, "main.go")}, &reply)
+	mockClock.Add(time.Minute)
+	s.OpenFile(pulse.Event{EditorID: "123", Path: filepath.Join(repositoryPath, "scratch.go")}, &reply)
+	mockClock.Add(5 * time.Minute)
+	s.EndSession(pulse.Event{EditorID: "123"}, &reply)
+	s.Shutdown(time.Minute)
+
+	storedSessions := mockStorage.GetSessions()
+	if len(storedSessions) != 1 {
+		t.Fatalf("expected sessions %d; got %d", 1, len(storedSessions))
+	}
+	session := storedSessions[0]
+	if session.TotalTimeMs != (2 * time.Minute).Milliseconds() {
+		t.Errorf("expected the sessions duration to be %d; got %d", (2 * time.Minute).Milliseconds(), session.TotalTimeMs)
+	}
+	if len(session.Repositories) != 1 || len(session.Repositories[0].Files) != 1 {
+		t.Fatalf("expected only main.go to be tracked; got %+v", session.Repositories)
+	}
+}
+
+func TestServerKeepsFilesAtTheSocketPath(t *testing.T) {
+	t.Parallel()
+
+	// A file that was configured as the socket by mistake.
+	path := filepath.Join(t.TempDir(), "notes.txt")
+	err := os.WriteFile(path, []byte("important"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.Socket = path
+	s := server.New(&cfg, t.TempDir(), newMockStorage(), server.WithLog(log.New(io.Discard)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err = s.StartServer(ctx); err == nil {
+		t.Fatal("expected the server to refuse to replace the file")
+	}
+	if content, err := os.ReadFile(path); err != nil || string(content) != "important" {
+		t.Errorf("expected the file to be left untouched; got %q, %v", content, err)
+	}
+}
+
+func TestServerCreatesPrivateSockets(t *testing.T) {
+	t.Parallel()
+	if runtime.GOOS == "windows" {
+		t.Skip("the permissions of unix sockets aren't enforced on windows")
+	}
+
+	path := filepath.Join(t.TempDir(), "pulse.sock")
+	var cfg pulse.Config
+	cfg.Server.Name = "TestApp"
+	cfg.Server.Socket = path
+	s := server.New(&cfg, t.TempDir(), newMockStorage(), server.WithLog(log.New(io.Discard)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.StartServer(ctx) }()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	for i := 0; i < 100 && s.Addr() == nil; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("expected the socket to be private to the user; got %o", perm)
+	}
+}
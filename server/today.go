@@ -0,0 +1,142 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+const (
+	// remoteReadTimeout is the maximum amount of time we'll wait for
+	// the permanent storage when we seed the cache for today.
+	remoteReadTimeout = 10 * time.Second
+	// todayAttempts is the number of times we'll try to read the buffers in
+	// the log without holding the lock, before we give up and hold it.
+	todayAttempts = 3
+)
+
+// addAggregated adds a session that has been aggregated from the log to the
+// time for today. It's counted as soon as it leaves the log, rather than
+// when it reaches the permanent storage, so that the time doesn't drop
+// while the write is pending. Should be called with a lock.
+func (s *Server) addAggregated(session pulse.CodingSession) {
+	if session.DateString != s.calendar.DateString(s.clock.Now()) {
+		return
+	}
+	if s.aggregatedToday.DateString != session.DateString {
+		s.aggregatedToday = pulse.NewSummary(session.DateString)
+	}
+	s.aggregatedToday.AddSession(session)
+}
+
+// remoteLoaded marks the time of today in the permanent storage as loaded,
+// which allows the writes to the permanent storage to proceed.
+func (s *Server) remoteLoaded() {
+	s.remoteLoadedOnce.Do(func() { close(s.remoteLoadedCh) })
+}
+
+// loadRemote seeds the cached summary with the time that was written to the
+// permanent storage earlier today, e.g. before the server was restarted. The
+// writes of this server are held back until it's done. The time that we've
+// aggregated, and the time that we've read, can therefore never overlap.
+func (s *Server) loadRemote(ctx context.Context) {
+	defer s.remoteLoaded()
+	if s.sessionReader == nil {
+		return
+	}
+
+	s.mu.Lock()
+	today := s.calendar.TruncateDay(s.clock.Now().UnixMilli())
+	s.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, remoteReadTimeout)
+	defer cancel()
+	session, err := s.sessionReader.Read(ctx, today)
+	if err != nil {
+		s.log.Errorf("Failed to read today's session from the permanent storage: %v", err)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.remoteToday = pulse.NewSummary(session.DateString)
+	s.remoteToday.AddSession(session)
+}
+
+// todayState is a copy of the state that the time for today is computed from.
+type todayState struct {
+	date       string
+	summary    pulse.Summary
+	logVersion uint64
+}
+
+// copyToday copies the time of today that isn't in the log. The active
+// buffer is cheap to split, which is why it's done here rather than
+// in the caller. Should be called with a lock.
+func (s *Server) copyToday() todayState {
+	now := s.clock.Now()
+	state := todayState{
+		date:       s.calendar.DateString(now),
+		logVersion: s.logVersion,
+	}
+	state.summary = pulse.NewSummary(state.date)
+
+	if s.remoteToday.DateString == state.date {
+		state.summary.AddSummary(s.remoteToday)
+	}
+	if s.aggregatedToday.DateString == state.date {
+		state.summary.AddSummary(s.aggregatedToday)
+	}
+	if s.activeBuffer != nil {
+		buf := *s.activeBuffer
+		for _, piece := range buf.Split(s.calendar, s.lastHeartbeat.Add(s.idleGracePeriod), now) {
+			if s.calendar.DateString(piece.OpenedAt) == state.date {
+				state.summary.AddBuffer(piece)
+			}
+		}
+	}
+	return state
+}
+
+// Today returns the time that has been tracked today. It combines the
+// elapsed time of the active buffer, the buffers that have yet to be
+// aggregated, and the sessions that have been aggregated from the log.
+//
+// The log is read without holding the lock, so that we don't block the
+// heartbeats. If a buffer was written to, or aggregated from, the log
+// while we were reading it, we'd risk counting it twice or not at all.
+// We start over if that happens.
+func (s *Server) Today(reply *pulse.Summary) {
+	var state todayState
+	var values map[string][]byte
+	for attempt := 1; ; attempt++ {
+		s.mu.Lock()
+		state = s.copyToday()
+		if attempt == todayAttempts {
+			values = s.db.GetByPrefix(state.date + "_")
+			s.mu.Unlock()
+			break
+		}
+		s.mu.Unlock()
+
+		values = s.db.GetByPrefix(state.date + "_")
+		s.mu.Lock()
+		unchanged := s.logVersion == state.logVersion
+		s.mu.Unlock()
+		if unchanged {
+			break
+		}
+	}
+
+	for _, value := range values {
+		var buf pulse.Buffer
+		if err := json.Unmarshal(value, &buf); err != nil {
+			s.log.Errorf("Failed to decode a buffer: %v", err)
+			continue
+		}
+		state.summary.AddBuffer(buf)
+	}
+	*reply = state.summary
+}
@@ -2,6 +2,7 @@ package pulse
 
 import (
 	"cmp"
+	"slices"
 	"time"
 
 	"golang.org/x/exp/maps"
@@ -20,46 +21,160 @@ const (
 // CodingSession represents a coding session that has been aggregated
 // for a given time period (day, week, month, year).
 type CodingSession struct {
-	ID           string       `bson:"_id,omitempty"`
-	Period       Period       `bson:"period"`
-	EpochDateMs  int64        `bson:"date"`
-	DateString   string       `bson:"date_string"`
-	TotalTimeMs  int64        `bson:"total_time_ms"`
-	Repositories Repositories `bson:"repositories"`
+	ID            string       `bson:"_id,omitempty"`
+	Period        Period       `bson:"period"`
+	EpochDateMs   int64        `bson:"date"`
+	DateString    string       `bson:"date_string"`
+	TotalTimeMs   int64        `bson:"total_time_ms"`
+	EditingTimeMs int64        `bson:"editing_time_ms"`
+	ReadingTimeMs int64        `bson:"reading_time_ms"`
+	Writes        int          `bson:"writes"`
+	LinesAdded    int          `bson:"lines_added"`
+	LinesRemoved  int          `bson:"lines_removed"`
+	Repositories  Repositories `bson:"repositories"`
+	Tickets       Tickets      `bson:"tickets"`
+	// Goals is the progress towards the goals. It's only
+	// evaluated for the sessions that span a single day.
+	Goals []GoalProgress `bson:"goals,omitempty"`
+	// WriteIDs identify the writes that have been merged into the session. A
+	// write that is retried after it has already been stored can then be
+	// recognized, rather than being counted twice.
+	WriteIDs []string `bson:"write_ids,omitempty"`
 }
 
-func NewCodingSession(buffers Buffers, now time.Time) CodingSession {
+// newRepository creates an empty repository that a session can be aggregated into.
+func newRepository(name string) Repository {
+	return Repository{
+		Name:     name,
+		Files:    make(Files, 0),
+		Branches: make(Branches, 0),
+		Commits:  make(Commits, 0),
+	}
+}
+
+// NewCodingSessions aggregates the buffers and commits into one coding session
+// for each day of the calendar, in chronological order. Buffers are attributed to the day that
+// they were opened, and commits to the day that they were made. Those without
+// a time, e.g. buffers that were stored before the time was, belong to now.
+func NewCodingSessions(cal Calendar, buffers Buffers, commits Commits, now time.Time) CodingSessions {
+	type day struct {
+		at      time.Time
+		buffers Buffers
+		commits Commits
+	}
+	days := make(map[int64]*day)
+	dayOf := func(t time.Time) *day {
+		if t.IsZero() {
+			t = now
+		}
+		epochDateMs := cal.TruncateDay(t.UnixMilli())
+		if _, ok := days[epochDateMs]; !ok {
+			days[epochDateMs] = &day{at: t}
+		}
+		return days[epochDateMs]
+	}
+	for _, buf := range buffers {
+		d := dayOf(buf.OpenedAt)
+		d.buffers = append(d.buffers, buf)
+	}
+	for _, commit := range commits {
+		d := dayOf(commit.CommittedAt)
+		d.commits = append(d.commits, commit)
+	}
+
+	sessions := make(CodingSessions, 0, len(days))
+	for _, d := range days {
+		sessions = append(sessions, newDailySession(cal, d.buffers, d.commits, d.at))
+	}
+	slices.SortFunc(sessions, func(a, b CodingSession) int {
+		return cmp.Compare(a.EpochDateMs, b.EpochDateMs)
+	})
+	return sessions
+}
+
+// newDailySession aggregates the buffers and commits of a single day into a coding session.
+func newDailySession(cal Calendar, buffers Buffers, commits Commits, day time.Time) CodingSession {
 	repos := make(map[string]Repository)
+	tickets := make(Tickets, 0)
 	for _, buf := range buffers {
 		repo, ok := repos[buf.Repository]
 		if !ok {
-			repo = Repository{Name: buf.Repository, Files: make(Files, 0)}
+			repo = newRepository(buf.Repository)
 		}
+		repo.Workspace = repo.Workspace || buf.Workspace
+		repo.Category = cmp.Or(repo.Category, buf.Category)
+		repo.Redaction = cmp.Or(repo.Redaction, buf.Redaction)
 
 		file := File{
-			Name:       buf.Filename,
-			Path:       buf.Filepath,
-			Filetype:   buf.Filetype,
-			DurationMs: buf.Duration.Milliseconds(),
+			Name:         buf.Filename,
+			Path:         buf.Filepath,
+			Filetype:     buf.Filetype,
+			DurationMs:   buf.Duration.Milliseconds(),
+			EditingMs:    buf.EditDuration.Milliseconds(),
+			ReadingMs:    buf.ReadDuration.Milliseconds(),
+			Writes:       buf.Writes,
+			LinesAdded:   buf.LinesAdded,
+			LinesRemoved: buf.LinesRemoved,
 		}
 		repo.DurationMs += file.DurationMs
-		repo.Files = append(repo.Files, file)
+		repo.EditingMs += file.EditingMs
+		repo.ReadingMs += file.ReadingMs
+		repo.Writes += file.Writes
+		repo.LinesAdded += file.LinesAdded
+		repo.LinesRemoved += file.LinesRemoved
+		// The same file can be opened on several branches during a day.
+		if buf.Project == "" {
+			repo.Files = repo.Files.merge(Files{file})
+		} else {
+			project := Project{
+				Name:         buf.Project,
+				Files:        Files{file},
+				DurationMs:   file.DurationMs,
+				EditingMs:    file.EditingMs,
+				ReadingMs:    file.ReadingMs,
+				Writes:       file.Writes,
+				LinesAdded:   file.LinesAdded,
+				LinesRemoved: file.LinesRemoved,
+			}
+			repo.Projects = repo.Projects.merge(Projects{project})
+		}
+		if buf.Branch != "" {
+			branch := Branch{Name: buf.Branch, DurationMs: file.DurationMs}
+			repo.Branches = repo.Branches.merge(Branches{branch})
+		}
 		repos[buf.Repository] = repo
+
+		// A branch that references several tickets attributes the time to each of them.
+		for _, id := range buf.Tickets {
+			ticket := Ticket{ID: id, DurationMs: file.DurationMs, Repositories: []string{buf.Repository}}
+			tickets = tickets.merge(Tickets{ticket})
+		}
 	}
 
-	var totalDurationMS int64
-	repositories := make(Repositories, 0, len(repos))
-	for _, repo := range repos {
-		totalDurationMS += repo.DurationMs
-		repositories = append(repositories, repo)
+	for _, commit := range commits {
+		repo, ok := repos[commit.Repository]
+		if !ok {
+			repo = newRepository(commit.Repository)
+		}
+		repo.Commits = repo.Commits.merge(Commits{commit})
+		repos[commit.Repository] = repo
 	}
 
 	session := CodingSession{
 		Period:       Day,
-		EpochDateMs:  TruncateDay(now.UnixMilli()),
-		DateString:   now.Format("2006-01-02"),
-		TotalTimeMs:  totalDurationMS,
-		Repositories: repositories,
+		EpochDateMs:  cal.TruncateDay(day.UnixMilli()),
+		DateString:   cal.DateString(day),
+		Repositories: make(Repositories, 0, len(repos)),
+		Tickets:      tickets,
+	}
+	for _, repo := range repos {
+		session.TotalTimeMs += repo.DurationMs
+		session.EditingTimeMs += repo.EditingMs
+		session.ReadingTimeMs += repo.ReadingMs
+		session.Writes += repo.Writes
+		session.LinesAdded += repo.LinesAdded
+		session.LinesRemoved += repo.LinesRemoved
+		session.Repositories = append(session.Repositories, repo)
 	}
 	return session
 }
@@ -67,11 +182,18 @@ func NewCodingSession(buffers Buffers, now time.Time) CodingSession {
 // merge takes two coding sessions, merges them, and returns the result.
 func (a CodingSession) merge(b CodingSession, epochDateMs int64, timePeriod Period) CodingSession {
 	mergedSession := CodingSession{
-		Period:       timePeriod,
-		EpochDateMs:  epochDateMs,
-		DateString:   cmp.Or(a.DateString, b.DateString),
-		TotalTimeMs:  a.TotalTimeMs + b.TotalTimeMs,
-		Repositories: a.Repositories.merge(b.Repositories),
+		Period:        timePeriod,
+		EpochDateMs:   epochDateMs,
+		DateString:    cmp.Or(a.DateString, b.DateString),
+		TotalTimeMs:   a.TotalTimeMs + b.TotalTimeMs,
+		EditingTimeMs: a.EditingTimeMs + b.EditingTimeMs,
+		ReadingTimeMs: a.ReadingTimeMs + b.ReadingTimeMs,
+		Writes:        a.Writes + b.Writes,
+		LinesAdded:    a.LinesAdded + b.LinesAdded,
+		LinesRemoved:  a.LinesRemoved + b.LinesRemoved,
+		Repositories:  a.Repositories.merge(b.Repositories),
+		Tickets:       a.Tickets.merge(b.Tickets),
+		WriteIDs:      append(slices.Clip(a.WriteIDs), b.WriteIDs...),
 	}
 
 	return mergedSession
@@ -91,22 +213,22 @@ func merge(sessions CodingSessions, truncate func(int64) int64, timePeriod Perio
 	return maps.Values(truncatedDateAggregatedSession)
 }
 
-// MergeByDay merges sessions that occurred the same day.
-func (s CodingSessions) MergeByDay() CodingSessions {
-	return merge(s, TruncateDay, Day)
+// MergeByDay merges sessions that occurred the same day of the calendar.
+func (s CodingSessions) MergeByDay(cal Calendar) CodingSessions {
+	return merge(s, cal.TruncateDay, Day)
 }
 
-// MergeByWeek merges sessions that occurred the same week.
-func (s CodingSessions) MergeByWeek() CodingSessions {
-	return merge(s, TruncateWeek, Week)
+// MergeByWeek merges sessions that occurred the same week of the calendar.
+func (s CodingSessions) MergeByWeek(cal Calendar) CodingSessions {
+	return merge(s, cal.TruncateWeek, Week)
 }
 
-// MergeByWeek merges sessions that occurred the same month.
-func (s CodingSessions) MergeByMonth() CodingSessions {
-	return merge(s, TruncateMonth, Month)
+// MergeByMonth merges sessions that occurred the same month of the calendar.
+func (s CodingSessions) MergeByMonth(cal Calendar) CodingSessions {
+	return merge(s, cal.TruncateMonth, Month)
 }
 
-// MergeByYear merges sessions that occurred the same year.
-func (s CodingSessions) MergeByYear() CodingSessions {
-	return merge(s, TruncateYear, Year)
+// MergeByYear merges sessions that occurred the same year of the calendar.
+func (s CodingSessions) MergeByYear(cal Calendar) CodingSessions {
+	return merge(s, cal.TruncateYear, Year)
 }
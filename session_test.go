@@ -0,0 +1,167 @@
+package pulse_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/creativecreature/pulse"
+)
+
+func TestSessionTicketsSurviveMerges(t *testing.T) {
+	t.Parallel()
+
+	monday := time.Date(2023, time.June, 12, 10, 0, 0, 0, time.Local)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	mondayBuffer := pulse.Buffer{
+		Filename:   "main.go",
+		Filepath:   "pulse/main.go",
+		Filetype:   "go",
+		Repository: "pulse",
+		Branch:     "feat/PROJ-1234-some-title",
+		Tickets:    []string{"PROJ-1234"},
+		Duration:   time.Hour,
+	}
+	tuesdayBuffer := mondayBuffer
+	tuesdayBuffer.Duration = 2 * time.Hour
+
+	sessions := append(
+		pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{mondayBuffer}, pulse.Commits{}, monday),
+		pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{tuesdayBuffer}, pulse.Commits{}, tuesday)...,
+	)
+
+	merges := map[string]pulse.CodingSessions{
+		"week":  sessions.MergeByWeek(pulse.Calendar{}),
+		"month": sessions.MergeByMonth(pulse.Calendar{}),
+		"year":  sessions.MergeByYear(pulse.Calendar{}),
+	}
+	for period, merged := range merges {
+		if len(merged) != 1 {
+			t.Fatalf("expected one session per %s, got %d", period, len(merged))
+		}
+		if len(merged[0].Tickets) != 1 {
+			t.Fatalf("expected one ticket per %s, got %d", period, len(merged[0].Tickets))
+		}
+		ticket := merged[0].Tickets[0]
+		if ticket.ID != "PROJ-1234" || ticket.DurationMs != (3*time.Hour).Milliseconds() {
+			t.Errorf("expected PROJ-1234 to have %d ms per %s, got %+v", (3 * time.Hour).Milliseconds(), period, ticket)
+		}
+	}
+}
+
+func TestSessionProjectsSurviveMerges(t *testing.T) {
+	t.Parallel()
+
+	monday := time.Date(2023, time.June, 12, 10, 0, 0, 0, time.Local)
+	tuesday := monday.AddDate(0, 0, 1)
+
+	billing := pulse.Buffer{
+		Filename:   "main.go",
+		Filepath:   "monorepo/services/billing/main.go",
+		Filetype:   "go",
+		Repository: "monorepo",
+		Project:    "services/billing",
+		Duration:   time.Hour,
+	}
+	readme := pulse.Buffer{
+		Filename:   "README.md",
+		Filepath:   "monorepo/README.md",
+		Filetype:   "markdown",
+		Repository: "monorepo",
+		Duration:   time.Hour,
+	}
+
+	sessions := append(
+		pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{billing, readme}, pulse.Commits{}, monday),
+		pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{billing}, pulse.Commits{}, tuesday)...,
+	)
+
+	merged := sessions.MergeByWeek(pulse.Calendar{})
+	if len(merged) != 1 || len(merged[0].Repositories) != 1 {
+		t.Fatalf("expected one repository, got %+v", merged)
+	}
+	repo := merged[0].Repositories[0]
+	if len(repo.Files) != 1 || repo.Files[0].Path != readme.Filepath {
+		t.Errorf("expected the repository to only keep %s, got %+v", readme.Filepath, repo.Files)
+	}
+	if len(repo.Projects) != 1 {
+		t.Fatalf("expected one project, got %d", len(repo.Projects))
+	}
+	project := repo.Projects[0]
+	if project.Name != "services/billing" || project.DurationMs != (2*time.Hour).Milliseconds() {
+		t.Errorf("expected services/billing to have %d ms, got %+v", (2 * time.Hour).Milliseconds(), project)
+	}
+	if len(project.Files) != 1 || project.Files[0].Path != billing.Filepath {
+		t.Errorf("expected the project to have the file %s, got %+v", billing.Filepath, project.Files)
+	}
+
+	// The files of the projects count towards the summaries as well.
+	summary := pulse.NewSummary(merged[0].DateString)
+	summary.AddSession(merged[0])
+	if summary.Filetypes["go"] != (2*time.Hour).Milliseconds() || summary.TotalTimeMs != (3*time.Hour).Milliseconds() {
+		t.Errorf("expected the summary to include the files of the project, got %+v", summary)
+	}
+}
+
+func TestSessionsAreGroupedByDay(t *testing.T) {
+	t.Parallel()
+
+	// The buffers are opened on either side of midnight, and aggregated the next morning.
+	lateNight := time.Date(2024, time.January, 1, 23, 30, 0, 0, time.Local)
+	earlyMorning := time.Date(2024, time.January, 2, 0, 30, 0, 0, time.Local)
+	aggregatedAt := time.Date(2024, time.January, 2, 9, 0, 0, 0, time.Local)
+
+	buffers := pulse.Buffers{
+		{OpenedAt: lateNight, Filepath: "pulse/main.go", Filetype: "go", Repository: "pulse", Duration: 20 * time.Minute},
+		{OpenedAt: earlyMorning, Filepath: "pulse/main.go", Filetype: "go", Repository: "pulse", Duration: 10 * time.Minute},
+	}
+	commits := pulse.Commits{{SHA: "abc", Repository: "pulse", CommittedAt: lateNight.Add(25 * time.Minute)}}
+
+	sessions := pulse.NewCodingSessions(pulse.Calendar{}, buffers, commits, aggregatedAt)
+	if len(sessions) != 2 {
+		t.Fatalf("expected one session per day, got %d", len(sessions))
+	}
+
+	testCases := []struct {
+		dateString      string
+		epochDateMs     int64
+		totalTimeMs     int64
+		expectedCommits int
+	}{
+		{"2024-01-01", time.Date(2024, time.January, 1, 0, 0, 0, 0, time.Local).UnixMilli(), (20 * time.Minute).Milliseconds(), 1},
+		{"2024-01-02", time.Date(2024, time.January, 2, 0, 0, 0, 0, time.Local).UnixMilli(), (10 * time.Minute).Milliseconds(), 0},
+	}
+	for i, tc := range testCases {
+		session := sessions[i]
+		if session.DateString != tc.dateString || session.EpochDateMs != tc.epochDateMs {
+			t.Errorf("expected the session to be on %s (%d), got %s (%d)", tc.dateString, tc.epochDateMs, session.DateString, session.EpochDateMs)
+		}
+		if session.TotalTimeMs != tc.totalTimeMs {
+			t.Errorf("expected %d ms on %s, got %d", tc.totalTimeMs, tc.dateString, session.TotalTimeMs)
+		}
+		if len(session.Repositories[0].Commits) != tc.expectedCommits {
+			t.Errorf("expected %d commits on %s, got %d", tc.expectedCommits, tc.dateString, len(session.Repositories[0].Commits))
+		}
+	}
+}
+
+func TestSessionWriteIDsSurviveMerges(t *testing.T) {
+	t.Parallel()
+
+	morning := time.Date(2023, time.June, 12, 10, 0, 0, 0, time.Local)
+	buf := pulse.Buffer{Filepath: "pulse/main.go", Filetype: "go", Repository: "pulse", Duration: time.Hour}
+
+	first := pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{buf}, nil, morning)[0]
+	first.WriteIDs = []string{"a"}
+	second := pulse.NewCodingSessions(pulse.Calendar{}, pulse.Buffers{buf}, nil, morning.Add(time.Hour))[0]
+	second.WriteIDs = []string{"b"}
+
+	merged := pulse.CodingSessions{first, second}.MergeByDay(pulse.Calendar{})
+	if len(merged) != 1 {
+		t.Fatalf("expected one session, got %d", len(merged))
+	}
+	if !slices.Equal(merged[0].WriteIDs, []string{"a", "b"}) && !slices.Equal(merged[0].WriteIDs, []string{"b", "a"}) {
+		t.Errorf("expected the write IDs of both sessions, got %v", merged[0].WriteIDs)
+	}
+}
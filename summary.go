@@ -0,0 +1,57 @@
+package pulse
+
+// Summary represents the time that has been tracked for a single day,
+// broken down by repository and filetype. It's meant to be cheap to
+// compute, and is used to display the time in the editor.
+type Summary struct {
+	DateString   string
+	TotalTimeMs  int64
+	Repositories map[string]int64
+	Filetypes    map[string]int64
+}
+
+// NewSummary creates an empty summary for the given date.
+func NewSummary(dateString string) Summary {
+	return Summary{
+		DateString:   dateString,
+		Repositories: make(map[string]int64),
+		Filetypes:    make(map[string]int64),
+	}
+}
+
+// add adds the duration of a file to the summary.
+func (s *Summary) add(repository, filetype string, durationMs int64) {
+	s.TotalTimeMs += durationMs
+	s.Repositories[repository] += durationMs
+	s.Filetypes[filetype] += durationMs
+}
+
+// AddBuffer adds the duration of a buffer to the summary.
+func (s *Summary) AddBuffer(buf Buffer) {
+	s.add(buf.Repository, buf.Filetype, buf.Duration.Milliseconds())
+}
+
+// AddSummary adds the durations of another summary to this one.
+func (s *Summary) AddSummary(other Summary) {
+	s.TotalTimeMs += other.TotalTimeMs
+	for repository, durationMs := range other.Repositories {
+		s.Repositories[repository] += durationMs
+	}
+	for filetype, durationMs := range other.Filetypes {
+		s.Filetypes[filetype] += durationMs
+	}
+}
+
+// AddSession adds the durations of an aggregated coding session to the summary.
+func (s *Summary) AddSession(session CodingSession) {
+	for _, repo := range session.Repositories {
+		for _, file := range repo.Files {
+			s.add(repo.Name, file.Filetype, file.DurationMs)
+		}
+		for _, project := range repo.Projects {
+			for _, file := range project.Files {
+				s.add(repo.Name, file.Filetype, file.DurationMs)
+			}
+		}
+	}
+}
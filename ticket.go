@@ -0,0 +1,93 @@
+package pulse
+
+import (
+	"cmp"
+	"regexp"
+	"slices"
+)
+
+// Ticket represents the time that has been attributed to a ticket, or
+// issue, for a given time period. The ticket IDs are parsed from the
+// names of the branches that we've been working on. The repositories
+// of those branches are kept, so that the ticket can be redacted.
+type Ticket struct {
+	ID           string   `bson:"id"`
+	DurationMs   int64    `bson:"duration_ms"`
+	Repositories []string `bson:"repositories,omitempty"`
+}
+
+// merge takes two tickets, merges them, and returns the result.
+func (a Ticket) merge(b Ticket) Ticket {
+	repositories := append(slices.Clone(a.Repositories), b.Repositories...)
+	slices.Sort(repositories)
+	return Ticket{
+		ID:           cmp.Or(a.ID, b.ID),
+		DurationMs:   a.DurationMs + b.DurationMs,
+		Repositories: slices.Compact(repositories),
+	}
+}
+
+// Tickets represents a slice of tickets.
+type Tickets []Ticket
+
+// ticketsByID takes a slice of tickets and returns a map
+// where the ticket ID is the key and the ticket the value.
+func ticketsByID(tickets Tickets) map[string]Ticket {
+	idTicketMap := make(map[string]Ticket)
+	for _, ticket := range tickets {
+		idTicketMap[ticket.ID] = ticket
+	}
+	return idTicketMap
+}
+
+// merge takes two slices of tickets, merges them, and returns the result.
+func (a Tickets) merge(b Tickets) Tickets {
+	aIDs, bIDs := ticketsByID(a), ticketsByID(b)
+	allIDs := make(map[string]bool)
+	for id := range aIDs {
+		allIDs[id] = true
+	}
+	for id := range bIDs {
+		allIDs[id] = true
+	}
+
+	mergedTickets := make(Tickets, 0, len(allIDs))
+	for id := range allIDs {
+		mergedTickets = append(mergedTickets, aIDs[id].merge(bIDs[id]))
+	}
+	return mergedTickets
+}
+
+// CompileTicketPatterns compiles the regular expressions that are used to
+// extract ticket IDs from branch names. If a pattern contains a capturing
+// group, the first group is used as the ID. Otherwise, we use the entire match.
+func CompileTicketPatterns(patterns []string) ([]*regexp.Regexp, error) {
+	expressions := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		exp, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		expressions = append(expressions, exp)
+	}
+	return expressions, nil
+}
+
+// ParseTickets extracts the unique ticket IDs from the name of a branch.
+func ParseTickets(branch string, patterns []*regexp.Regexp) []string {
+	tickets := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, pattern := range patterns {
+		for _, match := range pattern.FindAllStringSubmatch(branch, -1) {
+			id := match[0]
+			if len(match) > 1 && match[1] != "" {
+				id = match[1]
+			}
+			if !seen[id] {
+				seen[id] = true
+				tickets = append(tickets, id)
+			}
+		}
+	}
+	return tickets
+}
@@ -0,0 +1,42 @@
+package pulse_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/creativecreature/pulse"
+)
+
+func TestParseTickets(t *testing.T) {
+	t.Parallel()
+
+	patterns, err := pulse.CompileTicketPatterns([]string{
+		`[A-Z][A-Z0-9]+-\d+`,
+		`(?:^|/)gh-(\d+)`,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		branch   string
+		expected []string
+	}{
+		{"feat/PROJ-1234-some-title", []string{"PROJ-1234"}},
+		{"fix/PROJ-1-and-OPS-22", []string{"PROJ-1", "OPS-22"}},
+		{"fix/gh-42-flaky-test", []string{"42"}},
+		{"PROJ-7/PROJ-7-again", []string{"PROJ-7"}},
+		{"main", []string{}},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.branch, func(t *testing.T) {
+			t.Parallel()
+			actual := pulse.ParseTickets(tc.branch, patterns)
+			if !slices.Equal(actual, tc.expected) {
+				t.Errorf("expected %v, got %v", tc.expected, actual)
+			}
+		})
+	}
+}
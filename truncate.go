@@ -2,30 +2,35 @@ package pulse
 
 import "time"
 
-const millisecondDay int64 = 24 * 60 * 60 * 1000
-
 // TruncateDay truncates the timestamp to the start of the day.
-func TruncateDay(timestamp int64) int64 {
-	return timestamp - (timestamp % millisecondDay)
+func (c Calendar) TruncateDay(timestamp int64) int64 {
+	return c.startOfDay(c.dateOf(time.UnixMilli(timestamp))).UnixMilli()
 }
 
 // TruncateWeek truncates the timestamp to the start of the week.
-func TruncateWeek(timestamp int64) int64 {
-	t := time.UnixMilli(timestamp)
+func (c Calendar) TruncateWeek(timestamp int64) int64 {
+	t := c.dateOf(time.UnixMilli(timestamp))
 	for t.Weekday() != time.Monday {
 		t = t.AddDate(0, 0, -1)
 	}
-	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location()).UnixMilli()
+	return c.startOfDay(t).UnixMilli()
 }
 
 // TruncateMonth truncates the timestamp to the start of the month.
-func TruncateMonth(timestamp int64) int64 {
-	t := time.UnixMilli(timestamp)
-	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location()).UnixMilli()
+func (c Calendar) TruncateMonth(timestamp int64) int64 {
+	t := c.dateOf(time.UnixMilli(timestamp))
+	return c.startOfDay(time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, t.Location())).UnixMilli()
 }
 
 // TruncateYear truncates the timestamp to the start of the year.
-func TruncateYear(timestamp int64) int64 {
-	t := time.UnixMilli(timestamp)
-	return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location()).UnixMilli()
+func (c Calendar) TruncateYear(timestamp int64) int64 {
+	t := c.dateOf(time.UnixMilli(timestamp))
+	return c.startOfDay(time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, t.Location())).UnixMilli()
+}
+
+// DayRange returns the first and last millisecond of the day that contains
+// the timestamp. Days aren't always 24 hours long, due to daylight saving time.
+func (c Calendar) DayRange(timestamp int64) (int64, int64) {
+	t := c.dateOf(time.UnixMilli(timestamp))
+	return c.startOfDay(t).UnixMilli(), c.startOfDay(t.AddDate(0, 0, 1)).UnixMilli() - 1
 }
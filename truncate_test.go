@@ -20,10 +20,11 @@ func TestMain(m *testing.M) {
 func TestTruncate(t *testing.T) {
 	t.Parallel()
 
+	var cal pulse.Calendar
 	// 09:32 Friday June 16 2023
 	originalTime := int64(1686907956000)
 	// 00:00 Friday June 16 2023
-	expectedDay := int64(1686873600000)
+	expectedDay := int64(1686866400000)
 	// 00:00 Monday June 12 2023
 	expectedWeek := int64(1686520800000)
 	// 00:00 Thursday June 01 2023
@@ -31,23 +32,114 @@ func TestTruncate(t *testing.T) {
 	// 00:00 Sunday Jan 01 2023
 	expectedYear := int64(1672527600000)
 
-	actualDay := pulse.TruncateDay(originalTime)
+	actualDay := cal.TruncateDay(originalTime)
 	if actualDay != expectedDay {
 		t.Errorf("Expected truncated day to be %d, got %d", expectedDay, actualDay)
 	}
 
-	actualWeek := pulse.TruncateWeek(originalTime)
+	actualWeek := cal.TruncateWeek(originalTime)
 	if actualWeek != expectedWeek {
 		t.Errorf("Expected truncated week to be %d, got %d", expectedWeek, actualWeek)
 	}
 
-	actualMonth := pulse.TruncateMonth(originalTime)
+	actualMonth := cal.TruncateMonth(originalTime)
 	if actualMonth != expectedMonth {
 		t.Errorf("Expected truncated month to be %d, got %d", expectedMonth, actualMonth)
 	}
 
-	actualYear := pulse.TruncateYear(originalTime)
+	actualYear := cal.TruncateYear(originalTime)
 	if actualYear != expectedYear {
 		t.Errorf("Expected truncated year to be %d, got %d", expectedYear, actualYear)
 	}
 }
+
+func TestDayRange(t *testing.T) {
+	t.Parallel()
+
+	// The clocks were moved forward an hour on March 26 2023 in Stockholm.
+	dst := time.Date(2023, time.March, 26, 12, 0, 0, 0, time.Local).UnixMilli()
+	start, end := pulse.Calendar{}.DayRange(dst)
+
+	expectedStart := time.Date(2023, time.March, 26, 0, 0, 0, 0, time.Local).UnixMilli()
+	if start != expectedStart {
+		t.Errorf("expected the day to start at %d, got %d", expectedStart, start)
+	}
+	if length := time.Duration(end-start+1) * time.Millisecond; length != 23*time.Hour {
+		t.Errorf("expected the day to be %s long, got %s", 23*time.Hour, length)
+	}
+}
+
+func TestDayStart(t *testing.T) {
+	t.Parallel()
+
+	cal := pulse.Calendar{DayStart: 4 * time.Hour}
+
+	// 02:00 Monday Jan 01 2024 counts towards Sunday Dec 31 2023.
+	lateNight := time.Date(2024, time.January, 1, 2, 0, 0, 0, time.Local)
+	if date := cal.DateString(lateNight); date != "2023-12-31" {
+		t.Errorf("expected the date to be %s, got %s", "2023-12-31", date)
+	}
+
+	testCases := []struct {
+		name     string
+		truncate func(int64) int64
+		expected time.Time
+	}{
+		{"day", cal.TruncateDay, time.Date(2023, time.December, 31, 4, 0, 0, 0, time.Local)},
+		{"week", cal.TruncateWeek, time.Date(2023, time.December, 25, 4, 0, 0, 0, time.Local)},
+		{"month", cal.TruncateMonth, time.Date(2023, time.December, 1, 4, 0, 0, 0, time.Local)},
+		{"year", cal.TruncateYear, time.Date(2023, time.January, 1, 4, 0, 0, 0, time.Local)},
+	}
+	for _, tc := range testCases {
+		if actual := tc.truncate(lateNight.UnixMilli()); actual != tc.expected.UnixMilli() {
+			t.Errorf("expected the %s to start at %s, got %s", tc.name, tc.expected, time.UnixMilli(actual))
+		}
+	}
+
+	// The clocks were moved forward an hour at 02:00 on March 26 2023 in
+	// Stockholm, which shortens the day that started at 04:00 the day before.
+	start, end := cal.DayRange(time.Date(2023, time.March, 25, 12, 0, 0, 0, time.Local).UnixMilli())
+	expectedStart := time.Date(2023, time.March, 25, 4, 0, 0, 0, time.Local)
+	if start != expectedStart.UnixMilli() {
+		t.Errorf("expected the day to start at %s, got %s", expectedStart, time.UnixMilli(start))
+	}
+	if length := time.Duration(end-start+1) * time.Millisecond; length != 23*time.Hour {
+		t.Errorf("expected the day to be %s long, got %s", 23*time.Hour, length)
+	}
+
+	parsed, err := cal.ParseDate("2023-12-31")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !parsed.Equal(testCases[0].expected) {
+		t.Errorf("expected the date to start at %s, got %s", testCases[0].expected, parsed)
+	}
+
+	// A buffer and a session that are opened after midnight are stored under the previous day.
+	buf := pulse.Buffer{OpenedAt: lateNight, Filepath: "pulse/main.go", Repository: "pulse", Duration: time.Minute}
+	if key := buf.Key(cal); key != "2023-12-31_pulse__pulse/main.go" {
+		t.Errorf("expected the key to be %s, got %s", "2023-12-31_pulse__pulse/main.go", key)
+	}
+	sessions := pulse.NewCodingSessions(cal, pulse.Buffers{buf}, nil, lateNight)
+	if len(sessions) != 1 || sessions[0].DateString != "2023-12-31" || sessions[0].EpochDateMs != testCases[0].expected.UnixMilli() {
+		t.Errorf("expected a single session on %s, got %+v", "2023-12-31", sessions)
+	}
+}
+
+func TestCalendarLocation(t *testing.T) {
+	t.Parallel()
+
+	// 00:30 Monday Jan 01 2024 in Stockholm is 23:30 on Sunday in UTC.
+	justAfterMidnight := time.Date(2024, time.January, 1, 0, 30, 0, 0, time.Local)
+	if date := (pulse.Calendar{}).DateString(justAfterMidnight); date != "2024-01-01" {
+		t.Errorf("expected the local date to be %s, got %s", "2024-01-01", date)
+	}
+	utc := pulse.Calendar{Location: time.UTC}
+	if date := utc.DateString(justAfterMidnight); date != "2023-12-31" {
+		t.Errorf("expected the UTC date to be %s, got %s", "2023-12-31", date)
+	}
+	expected := time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC).UnixMilli()
+	if day := utc.TruncateDay(justAfterMidnight.UnixMilli()); day != expected {
+		t.Errorf("expected the day to start at %d, got %d", expected, day)
+	}
+}
@@ -0,0 +1,26 @@
+package pulse
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Workspace maps a directory that isn't under source control to a project
+// name. Files within the directory are attributed to the project.
+type Workspace struct {
+	Name string
+	Path string
+}
+
+// Root returns the absolute path of the workspace. Environment
+// variables, and a leading ~, are expanded.
+func (w Workspace) Root() string {
+	root := os.ExpandEnv(w.Path)
+	if root == "~" || strings.HasPrefix(root, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			root = home + root[1:]
+		}
+	}
+	return filepath.Clean(root)
+}